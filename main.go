@@ -1,30 +1,42 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/robfig/cron/v3"
 	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
 	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/ldap"
 	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/server"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/sink"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/syncer"
 )
 
-// Define a struct that matches the ACL file structure
-type ACL struct {
-	Groups map[string][]string `json:"groups"`
-	ACLs   json.RawMessage     `json:"acls"`
-}
-
 func main() {
+	dryRun := flag.Bool("dry-run", false, "print the policy changes of a single sync without writing anything")
+	force := flag.Bool("force", false, "apply the sync even if it exceeds the removal limits")
+	listBackups := flag.Bool("list-backups", false, "list the backups of the ACL file and exit")
+	rollback := flag.String("rollback", "", "restore the ACL file from the named backup (or \"latest\"), reload Headscale and exit")
+	flag.Parse()
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
 		os.Exit(1)
 	}
+	if *dryRun {
+		cfg.App.IsDryRun = true
+	}
+	if *force {
+		cfg.App.IsForce = true
+	}
 
 	log, err := logger.NewLogger(*cfg, nil)
 	if err != nil {
@@ -35,15 +47,59 @@ func main() {
 	log.Debug("Starting logs...")
 	log.Info("Configuration loaded successfully")
 
+	if *listBackups || *rollback != "" {
+		if err := runBackupCommand(cfg, log, *listBackups, *rollback); err != nil {
+			log.Error("Backup command failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	policySink, err := sink.NewSink(cfg, log)
+	if err != nil {
+		log.Error("Failed to create policy sink", "error", err)
+		os.Exit(1)
+	}
+	log.Info("Policy sink configured", "sink", policySink.Name())
+
+	identitySource := ldap.NewSource(cfg.Ldap, syncer.Ownership(cfg.App), log)
+	s := syncer.NewSyncer(cfg, identitySource, policySink, log)
+
+	if cfg.App.IsDryRun {
+		log.Info("Dry run enabled, running a single sync without writing...")
+		if _, err := s.DryRun(context.Background(), os.Stdout); err != nil {
+			log.Error("Dry run failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	schedule := cfg.App.CronSchedule
+	interval, err := scheduleInterval(schedule)
+	if err != nil {
+		log.Error("Failed to parse cron schedule", "schedule", schedule, "error", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Start HTTP server if enabled in config
+	var srv *server.Server
+	if cfg.App.IsServer {
+		readyWindow := time.Duration(cfg.App.ReadyIntervals) * interval
+		srv = server.NewServer(cfg.App, s, identitySource, readyWindow, log)
+		srv.Start()
+	}
+
 	log.Info("Running initial sync...")
-	syncACL(cfg, log)
+	go syncACL(s, log)
 
 	// Start cron scheduler
 	c := cron.New()
-	schedule := cfg.App.CronSchedule
 	_, err = c.AddFunc(schedule, func() {
 		log.Debug("Cron job triggered, running sync...")
-		syncACL(cfg, log)
+		syncACL(s, log)
 	})
 	if err != nil {
 		log.Error("Failed to add cron job", "error", err)
@@ -52,126 +108,109 @@ func main() {
 
 	log.Info("Cron scheduler started", "schedule", schedule)
 	c.Start()
-	defer c.Stop()
 
-	// Keep the main function running
 	log.Info("Application is running and waiting for scheduled jobs...")
-	select {}
+	<-ctx.Done()
+
+	// Restore the default signal handling, so a second signal terminates immediately
+	stop()
+	os.Exit(shutdown(c, s, srv, cfg.App.ShutdownTimeout, log))
 }
 
-func syncACL(cfg *config.Config, log logger.ILogger) {
-	log.Debug("Starting LDAP client setup...")
-	ldapClient, err := ldap.NewClient(cfg.Ldap, log)
-	if err != nil {
-		log.Error("Failed to create LDAP client", "error", err)
-		return
+// httpShutdownTimeout limits how long open HTTP requests may take once the running sync has finished
+const httpShutdownTimeout = 3 * time.Second
+
+// shutdown stops the HTTP listener and the scheduler, waits up to timeout for the running sync and then
+// for the open HTTP requests. It returns the exit code: 0 after a clean shutdown, 1 if a sync had to be
+// cancelled or the server failed to stop.
+func shutdown(c *cron.Cron, s *syncer.Syncer, srv *server.Server, timeout time.Duration, log logger.ILogger) int {
+	log.Info("Shutting down, waiting for the running sync to finish...", "timeout", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Stop accepting requests right away, a POST /sync in flight finishes together with the running sync
+	httpCtx, httpCancel := context.WithCancel(context.Background())
+	defer httpCancel()
+	httpDone := make(chan error, 1)
+	if srv != nil {
+		go func() { httpDone <- srv.Shutdown(httpCtx) }()
+	} else {
+		httpDone <- nil
 	}
-	defer ldapClient.Close()
-	log.Debug("LDAP client setup complete")
 
-	log.Info("Querying LDAP users with groups...")
-	users, err := ldapClient.QueryUsersWithGroups()
-	if err != nil {
-		log.Error("Failed to query LDAP users with roles", "error", err)
-		return
+	code := 0
+	c.Stop()
+	if err := s.Shutdown(ctx); err != nil {
+		log.Error("Running sync did not finish in time and was cancelled", "error", err)
+		code = 1
 	}
-	log.Info("LDAP query complete", "total_users", len(users))
 
-	// Load existing ACL file
-	aclFilePath := cfg.App.AclJson
-	log.Debug("Reading existing ACL file", "path", aclFilePath)
-	aclData, err := os.ReadFile(aclFilePath)
-	if err != nil {
-		log.Error("Failed to read ACL file", "path", aclFilePath, "error", err)
-		return
+	timer := time.NewTimer(httpShutdownTimeout)
+	defer timer.Stop()
+	var httpErr error
+	select {
+	case httpErr = <-httpDone:
+	case <-timer.C:
+		httpCancel()
+		httpErr = <-httpDone
 	}
-
-	// Parse the ACL file
-	var existingACL ACL
-	log.Debug("Parsing existing ACL file")
-	if err := json.Unmarshal(aclData, &existingACL); err != nil {
-		log.Error("Failed to parse existing ACL file", "path", aclFilePath, "error", err)
-		return
+	if httpErr != nil {
+		log.Error("Failed to shut down HTTP server", "error", httpErr)
+		code = 1
 	}
 
-	// Generate new groups from LDAP
-	log.Debug("Generating new groups from LDAP data")
-	newGroups := generateGroupsFromLDAP(users, cfg.App.GroupPrefix)
+	log.Info("Shutdown complete", "exit_code", code)
+	return code
+}
 
-	// Create updated structure preserving original acls as raw JSON
-	updatedACL := ACL{
-		Groups: newGroups,
-		ACLs:   existingACL.ACLs,
+// syncACL runs a single sync and logs its failure
+func syncACL(s *syncer.Syncer, log logger.ILogger) {
+	result, err := s.Run(context.Background())
+	if errors.Is(err, syncer.ErrSyncInProgress) || errors.Is(err, syncer.ErrShuttingDown) {
+		return
 	}
-
-	// Marshal updated file
-	log.Debug("Marshaling updated ACL file")
-	updatedJSON, err := json.MarshalIndent(updatedACL, "", "  ")
 	if err != nil {
-		log.Error("Failed to marshal updated ACL file", "error", err)
+		log.Error("Sync failed", "error", err)
 		return
 	}
+	log.Debug("Sync finished", "changed", result.Changed, "changed_groups", len(result.Diff))
+}
 
-	// Check if ACL content has changed
-	if string(updatedJSON) != string(aclData) {
-		log.Debug("ACL content changed, updating file...")
-		if err := os.WriteFile(aclFilePath, updatedJSON, 0644); err != nil {
-			log.Error("Failed to write ACL file", "path", aclFilePath, "error", err)
-			return
-		}
-
-		log.Info("ACL file updated successfully",
-			"path", aclFilePath,
-			"total_groups", len(newGroups),
-			"total_users_in_groups", countUniqueUsersInGroups(newGroups))
-
-		// Reload headscale container if enabled in config
-		if cfg.App.IsReloadHeadscale {
-			containerName := cfg.App.HeadscaleContainerName
-			log.Debug("Reloading headscale container", "container", containerName)
-			cmd := exec.Command("docker", "kill", "--signal=HUP", containerName)
-			if err := cmd.Run(); err != nil {
-				log.Error("Failed to reload headscale container", "error", err)
-			} else {
-				log.Info("Headscale container reloaded", "container", containerName)
-			}
-		} else {
-			log.Info("Headscale reload disabled in config")
-		}
-	} else {
-		log.Info("ACL file unchanged, no reload needed")
+// runBackupCommand lists the ACL file backups or restores one of them
+func runBackupCommand(cfg *config.Config, log logger.ILogger, list bool, rollback string) error {
+	if cfg.App.PolicyMode != sink.ModeFile {
+		return fmt.Errorf("backups are only available in %q policy mode", sink.ModeFile)
 	}
-}
+	policySink, err := sink.NewSink(cfg, log)
+	if err != nil {
+		return err
+	}
+	fileSink := policySink.(*sink.FileSink)
 
-// generateGroupsFromLDAP creates the groups map from LDAP data
-func generateGroupsFromLDAP(users []ldap.User, groupPrefix string) map[string][]string {
-	groupMap := make(map[string][]string)
-
-	for _, user := range users {
-		for _, group := range user.Groups {
-			if strings.HasPrefix(group.Name, groupPrefix) {
-				identifier := user.Email
-				if !strings.Contains(user.Email, "@") {
-					identifier = user.Username + "@"
-				}
-				key := "group:" + group.Name
-				groupMap[key] = append(groupMap[key], identifier)
-			}
+	if list {
+		backups, err := fileSink.Backups()
+		if err != nil {
+			return err
 		}
+		for _, backup := range backups {
+			fmt.Println(backup)
+		}
+		return nil
 	}
 
-	return groupMap
+	ctx := context.Background()
+	if err := fileSink.Rollback(ctx, rollback); err != nil {
+		return err
+	}
+	return fileSink.Notify(ctx)
 }
 
-// countUniqueUsersInGroups returns the total number of unique users across all groups
-func countUniqueUsersInGroups(groups map[string][]string) int {
-	userSet := make(map[string]bool)
-
-	for _, userEmails := range groups {
-		for _, email := range userEmails {
-			userSet[email] = true
-		}
+// scheduleInterval returns the time between two consecutive runs of a cron schedule
+func scheduleInterval(schedule string) (time.Duration, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return 0, err
 	}
-
-	return len(userSet)
+	next := sched.Next(time.Now())
+	return sched.Next(next).Sub(next), nil
 }
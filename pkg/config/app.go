@@ -1,23 +1,59 @@
 package config
 
+import "time"
+
 type AppConfig struct {
-	Port                   int    `validate:"omitempty,gt=0"`
-	Env                    string `validate:"omitempty,oneof=development test production"`
-	GroupPrefix            string `validate:"required"`
-	AclJson                string `validate:"required"`
-	IsReloadHeadscale      bool
-	HeadscaleContainerName string
-	CronSchedule           string `validate:"omitempty,cron"`
+	Port                 int `validate:"omitempty,gt=0"`
+	IsServer             bool
+	ReadyIntervals       int `validate:"gte=1"`
+	SyncToken            string
+	WebhookSecret        string
+	WebhookDebounce      time.Duration `validate:"gte=0"`
+	SyncTimeout          time.Duration `validate:"gte=0"`
+	ShutdownTimeout      time.Duration `validate:"gte=0"`
+	Env                  string        `validate:"omitempty,oneof=development test production"`
+	GroupPrefix          string        `validate:"required"`
+	ManagedGroups        []string
+	IsMergeMembers       bool
+	PolicyMode           string `validate:"omitempty,oneof=file api"`
+	AclJson              string `validate:"required_if=PolicyMode file"`
+	AclBackupDir         string
+	AclBackupCount       int `validate:"gte=0"`
+	IsAclRollback        bool
+	CronSchedule         string `validate:"omitempty,cron"`
+	IsDryRun             bool
+	MaxRemovedGroups     int `validate:"gte=0"`
+	MaxRemovedGroupsPct  int `validate:"gte=0,lte=100"`
+	MaxRemovedMembers    int `validate:"gte=0"`
+	MaxRemovedMembersPct int `validate:"gte=0,lte=100"`
+	IsForce              bool
 }
 
 func NewAppConfig() AppConfig {
 	return AppConfig{
-		Port:                   getEnvInt("APP_PORT", 8080),
-		Env:                    getEnvValue("APP_ENV", "production"),
-		GroupPrefix:            getEnvValue("APP_GROUP_PREFIX", ""),
-		AclJson:                getEnvValue("APP_ACL_JSON", ""),
-		IsReloadHeadscale:      getEnvBool("APP_IS_RELOAD_HEADSCALE", false),
-		HeadscaleContainerName: getEnvValue("APP_HEADSCALE_CONTAINER_NAME", "headscale"),
-		CronSchedule:           getEnvValue("APP_CRON_SCHEDULE", "@every 1h"),
+		Port:                 getEnvInt("APP_PORT", 8080),
+		IsServer:             getEnvBool("APP_IS_SERVER", false),
+		ReadyIntervals:       getEnvInt("APP_READY_INTERVALS", 3),
+		SyncToken:            getEnvValue("APP_SYNC_TOKEN", ""),
+		WebhookSecret:        getEnvValue("APP_WEBHOOK_SECRET", ""),
+		WebhookDebounce:      getEnvDuration("APP_WEBHOOK_DEBOUNCE", 10*time.Second),
+		SyncTimeout:          getEnvDuration("APP_SYNC_TIMEOUT", 5*time.Minute),
+		ShutdownTimeout:      getEnvDuration("APP_SHUTDOWN_TIMEOUT", 25*time.Second),
+		Env:                  getEnvValue("APP_ENV", "production"),
+		GroupPrefix:          getEnvValue("APP_GROUP_PREFIX", ""),
+		ManagedGroups:        getEnvList("APP_MANAGED_GROUPS", nil),
+		IsMergeMembers:       getEnvBool("APP_IS_MERGE_MEMBERS", false),
+		PolicyMode:           getEnvValue("APP_POLICY_MODE", "file"),
+		AclJson:              getEnvValue("APP_ACL_JSON", ""),
+		AclBackupDir:         getEnvValue("APP_ACL_BACKUP_DIR", ""),
+		AclBackupCount:       getEnvInt("APP_ACL_BACKUP_COUNT", 5),
+		IsAclRollback:        getEnvBool("APP_IS_ACL_ROLLBACK", true),
+		CronSchedule:         getEnvValue("APP_CRON_SCHEDULE", "@every 1h"),
+		IsDryRun:             getEnvBool("APP_IS_DRY_RUN", false),
+		MaxRemovedGroups:     getEnvInt("APP_MAX_REMOVED_GROUPS", 0),
+		MaxRemovedGroupsPct:  getEnvInt("APP_MAX_REMOVED_GROUPS_PERCENT", 50),
+		MaxRemovedMembers:    getEnvInt("APP_MAX_REMOVED_MEMBERS", 0),
+		MaxRemovedMembersPct: getEnvInt("APP_MAX_REMOVED_MEMBERS_PERCENT", 50),
+		IsForce:              getEnvBool("APP_IS_FORCE", false),
 	}
 }
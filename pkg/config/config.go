@@ -3,22 +3,28 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 	customValidator "hu.jandzsogyorgy.headscale-oidc-sync/pkg/validator"
 )
 
 type Config struct {
-	App  AppConfig
-	Log  LogConfig
-	Ldap LdapConfig
+	App       AppConfig
+	Log       LogConfig
+	Ldap      LdapConfig
+	Headscale HeadscaleConfig
+	Reload    ReloadConfig
 }
 
 func buildConfig() Config {
 	return Config{
-		App:  NewAppConfig(),
-		Log:  NewLogConfig(),
-		Ldap: NewLdapConfig(),
+		App:       NewAppConfig(),
+		Log:       NewLogConfig(),
+		Ldap:      NewLdapConfig(),
+		Headscale: NewHeadscaleConfig(),
+		Reload:    NewReloadConfig(),
 	}
 }
 
@@ -62,3 +68,27 @@ func getEnvBool(key string, fallback bool) bool {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	if value := getEnvValue(key, ""); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return fallback
+}
+
+func getEnvList(key string, fallback []string) []string {
+	value := getEnvValue(key, "")
+	if value == "" {
+		return fallback
+	}
+
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,17 @@
+package config
+
+import "time"
+
+type HeadscaleConfig struct {
+	URL     string `validate:"omitempty,url"`
+	APIKey  string
+	Timeout time.Duration `validate:"omitempty,gt=0"`
+}
+
+func NewHeadscaleConfig() HeadscaleConfig {
+	return HeadscaleConfig{
+		URL:     getEnvValue("HEADSCALE_URL", ""),
+		APIKey:  getEnvValue("HEADSCALE_API_KEY", ""),
+		Timeout: getEnvDuration("HEADSCALE_TIMEOUT", 10*time.Second),
+	}
+}
@@ -0,0 +1,35 @@
+package config
+
+import "time"
+
+type ReloadConfig struct {
+	Strategy    string `validate:"omitempty,oneof=docker pidfile systemd command none"`
+	Container   string `validate:"required_if=Strategy docker"`
+	DockerHost  string
+	PIDFile     string `validate:"required_if=Strategy pidfile"`
+	Unit        string `validate:"required_if=Strategy systemd"`
+	Command     string `validate:"required_if=Strategy command"`
+	IsVerify    bool
+	VerifyDelay time.Duration `validate:"gte=0"`
+	HealthURL   string        `validate:"omitempty,url"`
+}
+
+func NewReloadConfig() ReloadConfig {
+	// Without an explicit strategy, keep the previous docker on/off switch working
+	strategy := "none"
+	if getEnvBool("APP_IS_RELOAD_HEADSCALE", false) {
+		strategy = "docker"
+	}
+
+	return ReloadConfig{
+		Strategy:    getEnvValue("RELOAD_STRATEGY", strategy),
+		Container:   getEnvValue("APP_HEADSCALE_CONTAINER_NAME", "headscale"),
+		DockerHost:  getEnvValue("DOCKER_HOST", "unix:///var/run/docker.sock"),
+		PIDFile:     getEnvValue("RELOAD_PID_FILE", "/var/run/headscale/headscale.pid"),
+		Unit:        getEnvValue("RELOAD_SYSTEMD_UNIT", "headscale.service"),
+		Command:     getEnvValue("RELOAD_COMMAND", ""),
+		IsVerify:    getEnvBool("RELOAD_IS_VERIFY", true),
+		VerifyDelay: getEnvDuration("RELOAD_VERIFY_DELAY", 5*time.Second),
+		HealthURL:   getEnvValue("RELOAD_HEALTH_URL", ""),
+	}
+}
@@ -0,0 +1,155 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// requestTimeout limits a single Engine API request
+const requestTimeout = 10 * time.Second
+
+// DockerClient provides the container operations needed to reload Headscale.
+type DockerClient interface {
+	InspectContainer(ctx context.Context, name string) (*ContainerState, error)
+	SignalContainer(ctx context.Context, name, signal string) error
+}
+
+// Client implements DockerClient using the Docker Engine HTTP API.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	log        logger.ILogger
+}
+
+var _ DockerClient = (*Client)(nil)
+
+// ContainerState is the subset of the container state reported by the Engine API.
+type ContainerState struct {
+	Status     string `json:"Status"`
+	Running    bool   `json:"Running"`
+	Restarting bool   `json:"Restarting"`
+	ExitCode   int    `json:"ExitCode"`
+	Error      string `json:"Error"`
+	StartedAt  string `json:"StartedAt"`
+}
+
+// containerInspect is the subset of the inspect response used by the client.
+type containerInspect struct {
+	ID    string         `json:"Id"`
+	Name  string         `json:"Name"`
+	State ContainerState `json:"State"`
+}
+
+// apiError is the error body returned by the Engine API.
+type apiError struct {
+	Message string `json:"message"`
+}
+
+// NewClient creates a Docker Engine API client for a unix:// or tcp:// host.
+func NewClient(host string, log logger.ILogger) (*Client, error) {
+	if host == "" {
+		return nil, errors.New("docker host is not configured (DOCKER_HOST)")
+	}
+
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", host, err)
+	}
+
+	transport := &http.Transport{}
+	baseURL := ""
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+		baseURL = "http://docker"
+	case "tcp", "http":
+		baseURL = "http://" + u.Host
+	case "https":
+		baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme: %s", u.Scheme)
+	}
+
+	return &Client{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Transport: transport, Timeout: requestTimeout},
+		log:        log,
+	}, nil
+}
+
+// InspectContainer returns the state of the named container.
+func (c *Client) InspectContainer(ctx context.Context, name string) (*ContainerState, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var inspect containerInspect
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return nil, fmt.Errorf("failed to decode container %s: %w", name, err)
+	}
+
+	c.log.Debug("Inspected container", "container", name, "id", inspect.ID, "status", inspect.State.Status)
+	return &inspect.State, nil
+}
+
+// SignalContainer sends a signal (e.g. "HUP") to the main process of the named container.
+func (c *Client) SignalContainer(ctx context.Context, name, signal string) error {
+	query := url.Values{"signal": {signal}}
+	resp, err := c.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(name)+"/kill", query)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	c.log.Debug("Signaled container", "container", name, "signal", signal)
+	return nil
+}
+
+// do executes an API request and turns non-2xx responses into errors with the API message.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values) (*http.Response, error) {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c.log.Debug("Docker API request", "method", method, "path", path)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("docker API %s %s failed: %w", method, path, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+
+		message := strings.TrimSpace(string(data))
+		var apiErr apiError
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Message != "" {
+			message = apiErr.Message
+		}
+		return nil, fmt.Errorf("docker API %s %s returned %s: %s", method, path, resp.Status, message)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,124 @@
+package docker
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// newUnixClient serves handler on a unix socket and returns a client connected to it.
+func newUnixClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	// Unix socket paths are limited to about 100 bytes, keep it short
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	client, err := NewClient("unix://"+socket, log)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestInspectContainerNotRunning(t *testing.T) {
+	client := newUnixClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/containers/headscale/json" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		io.WriteString(w, `{"Id":"abc","Name":"/headscale","State":{"Status":"exited","Running":false,"ExitCode":1,"Error":"boom"}}`)
+	}))
+
+	state, err := client.InspectContainer(context.Background(), "headscale")
+	if err != nil {
+		t.Fatalf("InspectContainer: %v", err)
+	}
+	if state.Running || state.Status != "exited" || state.ExitCode != 1 || state.Error != "boom" {
+		t.Errorf("state = %+v", state)
+	}
+}
+
+func TestSignalContainer(t *testing.T) {
+	var called bool
+	client := newUnixClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if r.Method != http.MethodPost || r.URL.Path != "/containers/headscale/kill" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.URL.Query().Get("signal"); got != "HUP" {
+			t.Errorf("signal = %q, want HUP", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	if err := client.SignalContainer(context.Background(), "headscale", "HUP"); err != nil {
+		t.Fatalf("SignalContainer: %v", err)
+	}
+	if !called {
+		t.Error("kill endpoint was not called")
+	}
+}
+
+func TestAPIErrorMessage(t *testing.T) {
+	tests := map[string]struct {
+		body string
+		want string
+	}{
+		"json message": {`{"message":"No such container: headscale"}`, "404 Not Found: No such container: headscale"},
+		"plain body":   {"page not found\n", "404 Not Found: page not found"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newUnixClient(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+				io.WriteString(w, tt.body)
+			}))
+
+			_, err := client.InspectContainer(context.Background(), "headscale")
+			if err == nil {
+				t.Fatal("InspectContainer succeeded, want error")
+			}
+			if !strings.HasSuffix(err.Error(), tt.want) {
+				t.Errorf("error = %q, want suffix %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNewClientRequiresHost(t *testing.T) {
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	if _, err := NewClient("", log); err == nil {
+		t.Error("NewClient accepted an empty host")
+	}
+	if _, err := NewClient("ssh://docker", log); err == nil {
+		t.Error("NewClient accepted an unsupported scheme")
+	}
+}
@@ -0,0 +1,164 @@
+package headscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// Headscale REST API paths
+const (
+	policyPath = "/api/v1/policy"
+)
+
+// HeadscaleClient provides methods to read and replace the Headscale policy.
+type HeadscaleClient interface {
+	GetPolicy(ctx context.Context) (string, error)
+	SetPolicy(ctx context.Context, policy string) error
+}
+
+// Client implements HeadscaleClient using the Headscale REST API.
+type Client struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	log        logger.ILogger
+}
+
+// policyResponse is the body returned by the policy endpoints.
+type policyResponse struct {
+	Policy    string `json:"policy"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// setPolicyRequest is the body sent to the SetPolicy endpoint.
+type setPolicyRequest struct {
+	Policy string `json:"policy"`
+}
+
+// APIError is returned when the Headscale API answers with a non-2xx status.
+type APIError struct {
+	Method     string
+	Path       string
+	Status     string
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("headscale API %s %s returned %s: %s", e.Method, e.Path, e.Status, e.Body)
+}
+
+// isPolicyNotFound reports whether err is Headscale's answer for a database without a stored policy.
+// Headscale reports it through gRPC-gateway as an internal error, so the message is checked too.
+func isPolicyNotFound(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusNotFound || strings.Contains(apiErr.Body, "policy not found")
+}
+
+// NewClient creates a new Headscale API client with config and logger.
+func NewClient(cfg config.HeadscaleConfig, log logger.ILogger) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("headscale API URL is not configured (HEADSCALE_URL)")
+	}
+	if cfg.APIKey == "" {
+		return nil, errors.New("headscale API key is not configured (HEADSCALE_API_KEY)")
+	}
+
+	return &Client{
+		baseURL:    strings.TrimRight(cfg.URL, "/"),
+		apiKey:     cfg.APIKey,
+		httpClient: &http.Client{Timeout: cfg.Timeout},
+		log:        log,
+	}, nil
+}
+
+// GetPolicy returns the policy document currently stored in Headscale.
+// An empty string is returned when no policy has been stored yet.
+func (c *Client) GetPolicy(ctx context.Context) (string, error) {
+	var resp policyResponse
+	if err := c.do(ctx, http.MethodGet, policyPath, nil, &resp); err != nil {
+		if isPolicyNotFound(err) {
+			c.log.Info("No policy stored in Headscale yet, starting from an empty policy")
+			return "", nil
+		}
+		return "", err
+	}
+
+	c.log.Debug("Fetched policy from Headscale", "updated_at", resp.UpdatedAt)
+	return resp.Policy, nil
+}
+
+// SetPolicy replaces the policy document stored in Headscale.
+func (c *Client) SetPolicy(ctx context.Context, policy string) error {
+	var resp policyResponse
+	if err := c.do(ctx, http.MethodPut, policyPath, setPolicyRequest{Policy: policy}, &resp); err != nil {
+		return err
+	}
+
+	c.log.Debug("Stored policy in Headscale", "updated_at", resp.UpdatedAt)
+	return nil
+}
+
+// do executes an authenticated API request and decodes the JSON response into out.
+func (c *Client) do(ctx context.Context, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	c.log.Debug("Headscale API request", "method", method, "path", path)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.log.Error("Headscale API request failed", "method", method, "path", path, "error", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &APIError{
+			Method:     method,
+			Path:       path,
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Body:       strings.TrimSpace(string(data)),
+		}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
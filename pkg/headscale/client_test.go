@@ -0,0 +1,133 @@
+package headscale
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	client, err := NewClient(config.HeadscaleConfig{
+		URL:     srv.URL + "/",
+		APIKey:  "secret-key",
+		Timeout: 5 * time.Second,
+	}, log)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestGetPolicy(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != policyPath {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"policy":"{\"acls\":[]}","updatedAt":"2025-01-01T00:00:00Z"}`)
+	})
+
+	policy, err := client.GetPolicy(context.Background())
+	if err != nil {
+		t.Fatalf("GetPolicy: %v", err)
+	}
+	if policy != `{"acls":[]}` {
+		t.Errorf("policy = %q", policy)
+	}
+}
+
+func TestGetPolicyNotFound(t *testing.T) {
+	tests := map[string]struct {
+		status int
+		body   string
+	}{
+		"database without policy": {http.StatusInternalServerError, `{"code":2,"message":"loading ACL from database: acl policy not found","details":[]}`},
+		"not found status":        {http.StatusNotFound, `{"code":5,"message":"not found","details":[]}`},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = io.WriteString(w, tt.body)
+			})
+
+			policy, err := client.GetPolicy(context.Background())
+			if err != nil {
+				t.Fatalf("GetPolicy: %v", err)
+			}
+			if policy != "" {
+				t.Errorf("policy = %q, want empty", policy)
+			}
+		})
+	}
+}
+
+func TestSetPolicy(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != policyPath {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q", got)
+		}
+
+		var req setPolicyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		if req.Policy != `{"acls":[]}` {
+			t.Errorf("policy = %q", req.Policy)
+		}
+		_, _ = io.WriteString(w, `{"policy":"{\"acls\":[]}","updatedAt":"2025-01-01T00:00:00Z"}`)
+	})
+
+	if err := client.SetPolicy(context.Background(), `{"acls":[]}`); err != nil {
+		t.Fatalf("SetPolicy: %v", err)
+	}
+}
+
+func TestSetPolicyError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"code":3,"message":"setting policy: group \"group:ops\" is not defined"}`+"\n")
+	})
+
+	err := client.SetPolicy(context.Background(), `{"acls":[]}`)
+	if err == nil {
+		t.Fatal("SetPolicy succeeded, want error")
+	}
+
+	want := `headscale API PUT /api/v1/policy returned 400 Bad Request: {"code":3,"message":"setting policy: group \"group:ops\" is not defined"}`
+	if err.Error() != want {
+		t.Errorf("error = %q\nwant    %q", err.Error(), want)
+	}
+	if isPolicyNotFound(err) {
+		t.Error("isPolicyNotFound reported true for a validation error")
+	}
+	if !strings.Contains(err.Error(), "is not defined") {
+		t.Error("error does not include the response body")
+	}
+}
@@ -0,0 +1,57 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// groupNameFromDN returns the value of the naming attribute in the leaf RDN of dn, e.g. "Smith, John"
+// for `cn=Smith\, John,ou=groups,dc=example,dc=com`. Escaped and hex encoded values are decoded and
+// multi-valued RDNs are supported. If the leaf RDN has no naming attribute, its first value is used.
+func groupNameFromDN(dn, namingAttr string) (string, error) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return "", fmt.Errorf("invalid DN %q: %w", dn, err)
+	}
+	if len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return "", errors.New("empty DN")
+	}
+
+	leaf := parsed.RDNs[0]
+	for _, attr := range leaf.Attributes {
+		if strings.EqualFold(attr.Type, namingAttr) {
+			return attr.Value, nil
+		}
+	}
+	return leaf.Attributes[0].Value, nil
+}
+
+// groupName returns the name of the group with the given DN, empty if the DN cannot be parsed.
+func (c *Client) groupName(dn string) string {
+	name, err := groupNameFromDN(dn, c.config.GroupNameAttr)
+	if err != nil {
+		c.log.Warn("Skipping group with unparsable DN", "dn", dn, "error", err)
+		return ""
+	}
+	return name
+}
+
+// dnKey returns a normalized form of dn for comparisons, DNs are case-insensitive.
+func dnKey(dn string) string {
+	if parsed, err := ldap.ParseDN(dn); err == nil {
+		return strings.ToLower(parsed.String())
+	}
+	return strings.ToLower(strings.TrimSpace(dn))
+}
+
+// isDN reports whether value is a distinguished name rather than a plain identifier.
+func isDN(value string) bool {
+	if !strings.Contains(value, "=") {
+		return false
+	}
+	_, err := ldap.ParseDN(value)
+	return err == nil
+}
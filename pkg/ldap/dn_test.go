@@ -0,0 +1,52 @@
+package ldap
+
+import "testing"
+
+func TestGroupNameFromDN(t *testing.T) {
+	tests := []struct {
+		dn, attr, want string
+	}{
+		{"cn=admins,ou=groups,dc=example,dc=com", "cn", "admins"},
+		{"CN=Admins,OU=Groups,DC=example,DC=com", "cn", "Admins"},
+		{`cn=Smith\, John,ou=groups,dc=example,dc=com`, "cn", "Smith, John"},
+		{`cn=\23hash\3Dsign,dc=example,dc=com`, "cn", "#hash=sign"},
+		{"cn=caf\\C3\\A9,dc=example,dc=com", "cn", "café"},
+		{"ou=ops+cn=operators,dc=example,dc=com", "cn", "operators"},
+		{"ou=ops,dc=example,dc=com", "cn", "ops"},
+		{"gidNumber=1000+cn=devs,dc=example,dc=com", "CN", "devs"},
+	}
+
+	for _, tt := range tests {
+		got, err := groupNameFromDN(tt.dn, tt.attr)
+		if err != nil {
+			t.Errorf("groupNameFromDN(%q, %q): %v", tt.dn, tt.attr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("groupNameFromDN(%q, %q) = %q, want %q", tt.dn, tt.attr, got, tt.want)
+		}
+	}
+
+	for _, dn := range []string{"", "not a dn", "cn=unterminated\\"} {
+		if name, err := groupNameFromDN(dn, "cn"); err == nil {
+			t.Errorf("groupNameFromDN(%q) = %q, want error", dn, name)
+		}
+	}
+}
+
+func TestDNKey(t *testing.T) {
+	equal := [][2]string{
+		{"cn=Admins,ou=Groups,dc=Example,dc=com", "CN=admins, OU=groups, DC=example, DC=com"},
+		{`cn=Smith\, John,dc=example,dc=com`, `cn=smith\2c john,dc=example,dc=com`},
+		{" not a dn ", "NOT A DN"},
+	}
+	for _, pair := range equal {
+		if dnKey(pair[0]) != dnKey(pair[1]) {
+			t.Errorf("dnKey(%q) = %q, dnKey(%q) = %q, want equal", pair[0], dnKey(pair[0]), pair[1], dnKey(pair[1]))
+		}
+	}
+
+	if dnKey("cn=a,dc=example,dc=com") == dnKey("cn=b,dc=example,dc=com") {
+		t.Error("dnKey of different DNs is equal")
+	}
+}
@@ -8,6 +8,8 @@ type Group struct {
 	DisplayName string
 	Description string
 	Members     []string
+	MemberUIDs  []string
+	GIDNumber   string
 	Owner       string
 	Manager     string
 	MemberOf    []string
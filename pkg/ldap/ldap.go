@@ -1,12 +1,17 @@
 package ldap
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"strings"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
 	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
 )
 
 // LDAP attribute constants for users
@@ -38,7 +43,7 @@ const (
 type LDAPClient interface {
 	QueryUsers() ([]User, error)
 	QueryGroups() ([]Group, error)
-	QueryUsersWithGroups() ([]User, error)
+	QueryUsersWithGroups(ownership policy.Ownership) ([]User, error)
 	QueryUser(uid string) (*User, error)
 	QueryGroup(uid string) (*Group, error)
 	Close()
@@ -50,6 +55,10 @@ type Client struct {
 	config config.LdapConfig
 	log    logger.ILogger
 
+	// ctx bounds every request of the client, stop detaches it from the connection
+	ctx  context.Context
+	stop func() bool
+
 	// LDAP attribute names for flexibility
 	AttrUserUID       string
 	AttrUsername      string
@@ -60,6 +69,9 @@ type Client struct {
 	AttrGroupMember   string
 	AttrGroupDN       string
 	AttrGroupMemberOf string
+	// RFC 2307 posixGroup member usernames and the gidNumber shared with the primary group of a posixAccount
+	AttrGroupMemberUID string
+	AttrGIDNumber      string
 }
 
 // Common attribute sets reused across queries
@@ -89,41 +101,54 @@ var (
 )
 
 // NewClient creates a new LDAP client connection with config and logger.
-func NewClient(cfg config.LdapConfig, log logger.ILogger) (*Client, error) {
+// The connection is closed as soon as ctx is done, failing any request in flight.
+func NewClient(ctx context.Context, cfg config.LdapConfig, log logger.ILogger) (*Client, error) {
+	dialer := &net.Dialer{Timeout: ldap.DefaultTimeout}
+	if deadline, ok := ctx.Deadline(); ok {
+		dialer.Deadline = deadline
+	}
+
 	url := buildLDAPURL(cfg)
-	conn, err := ldap.DialURL(url)
+	conn, err := ldap.DialURL(url, ldap.DialWithDialer(dialer))
 	if err != nil {
 		log.Error("Failed to connect to LDAP", "error", err)
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
 
 	if strings.EqualFold(cfg.Protocol, "starttls") {
 		if err = conn.StartTLS(nil); err != nil {
 			log.Error("Failed to start TLS", "error", err)
+			stop()
 			conn.Close()
-			return nil, err
+			return nil, contextError(ctx, err)
 		}
 	}
 
 	if err = conn.Bind(cfg.BindDN, cfg.BindPW); err != nil {
 		log.Error("Failed to bind to LDAP", "error", err)
+		stop()
 		conn.Close()
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 
 	client := &Client{
-		conn:              conn,
-		config:            cfg,
-		log:               log,
-		AttrUserUID:       cfg.AttrUserUID,
-		AttrUsername:      cfg.AttrUserUsername,
-		AttrEmail:         cfg.AttrUserEmail,
-		AttrUserMemberOf:  cfg.AttrUserMemberOf,
-		AttrGroupUID:      cfg.AttrGroupUID,
-		AttrGroupCN:       cfg.AttrGroupCN,
-		AttrGroupMember:   cfg.AttrGroupMember,
-		AttrGroupDN:       cfg.AttrGroupDN,
-		AttrGroupMemberOf: cfg.AttrGroupMemberOf,
+		conn:               conn,
+		config:             cfg,
+		log:                log,
+		ctx:                ctx,
+		stop:               stop,
+		AttrUserUID:        cfg.AttrUserUID,
+		AttrUsername:       cfg.AttrUserUsername,
+		AttrEmail:          cfg.AttrUserEmail,
+		AttrUserMemberOf:   cfg.AttrUserMemberOf,
+		AttrGroupUID:       cfg.AttrGroupUID,
+		AttrGroupCN:        cfg.AttrGroupCN,
+		AttrGroupMember:    cfg.AttrGroupMember,
+		AttrGroupDN:        cfg.AttrGroupDN,
+		AttrGroupMemberOf:  cfg.AttrGroupMemberOf,
+		AttrGroupMemberUID: cfg.AttrGroupMemberUID,
+		AttrGIDNumber:      cfg.AttrGIDNumber,
 	}
 
 	log.Debug("Connected to LDAP")
@@ -143,6 +168,7 @@ func buildLDAPURL(cfg config.LdapConfig) string {
 
 // Close closes the LDAP connection.
 func (c *Client) Close() {
+	c.stop()
 	c.conn.Close()
 	c.log.Debug("LDAP connection closed")
 }
@@ -155,6 +181,9 @@ func (c *Client) makeUserAttrs(includeMemberOf bool) []string {
 		c.AttrEmail,
 	}
 	attrs = append(attrs, userBaseAttrs...)
+	if c.AttrGIDNumber != "" {
+		attrs = append(attrs, c.AttrGIDNumber)
+	}
 	if includeMemberOf {
 		attrs = append(attrs, c.AttrUserMemberOf)
 	}
@@ -171,6 +200,11 @@ func (c *Client) makeGroupAttrs() []string {
 		c.AttrGroupMemberOf,
 	}
 	attrs = append(attrs, groupBaseAttrs...)
+	for _, attr := range []string{c.AttrGroupMemberUID, c.AttrGIDNumber} {
+		if attr != "" {
+			attrs = append(attrs, attr)
+		}
+	}
 	return attrs
 }
 
@@ -195,6 +229,7 @@ func (c *Client) mapEntryToUser(entry *ldap.Entry, includeGroups bool) User {
 		HomeDirectory: entry.GetAttributeValue(UserAttrHomeDirectory),
 		LoginShell:    entry.GetAttributeValue(UserAttrLoginShell),
 		Manager:       entry.GetAttributeValue(UserAttrManager),
+		GIDNumber:     c.attributeValue(entry, c.AttrGIDNumber),
 		MemberOf:      entry.GetAttributeValues(c.AttrUserMemberOf),
 		WhenChanged:   entry.GetAttributeValue(UserAttrWhenChanged),
 		Info:          entry.GetAttributeValue(UserAttrInfo),
@@ -204,9 +239,8 @@ func (c *Client) mapEntryToUser(entry *ldap.Entry, includeGroups bool) User {
 	if includeGroups {
 		var userGroups []Group
 		for _, dn := range entry.GetAttributeValues(c.AttrUserMemberOf) {
-			cn := extractCN(dn)
-			if cn != "" {
-				userGroups = append(userGroups, Group{Name: cn, DN: dn})
+			if name := c.groupName(dn); name != "" {
+				userGroups = append(userGroups, Group{Name: name, DN: dn})
 			}
 		}
 		user.Groups = userGroups
@@ -215,6 +249,22 @@ func (c *Client) mapEntryToUser(entry *ldap.Entry, includeGroups bool) User {
 	return user
 }
 
+// attributeValue returns the first value of an optional attribute, empty if the attribute is not configured.
+func (c *Client) attributeValue(entry *ldap.Entry, attr string) string {
+	if attr == "" {
+		return ""
+	}
+	return entry.GetAttributeValue(attr)
+}
+
+// attributeValues returns the values of an optional attribute, nil if the attribute is not configured.
+func (c *Client) attributeValues(entry *ldap.Entry, attr string) []string {
+	if attr == "" {
+		return nil
+	}
+	return entry.GetAttributeValues(attr)
+}
+
 // mapEntryToGroup maps a single LDAP entry to Group struct.
 func (c *Client) mapEntryToGroup(entry *ldap.Entry) Group {
 	attrMap := make(map[string]string)
@@ -230,6 +280,8 @@ func (c *Client) mapEntryToGroup(entry *ldap.Entry) Group {
 		DN:          entry.DN,
 		Description: entry.GetAttributeValue(GroupAttrDescription),
 		Members:     entry.GetAttributeValues(c.AttrGroupMember),
+		MemberUIDs:  c.attributeValues(entry, c.AttrGroupMemberUID),
+		GIDNumber:   c.attributeValue(entry, c.AttrGIDNumber),
 		Owner:       entry.GetAttributeValue(GroupAttrOwner),
 		Manager:     entry.GetAttributeValue(GroupAttrManager),
 		MemberOf:    entry.GetAttributeValues(c.AttrGroupMemberOf),
@@ -305,8 +357,35 @@ func (c *Client) QueryGroups() ([]Group, error) {
 	return groups, nil
 }
 
-// QueryUsersWithGroups queries users including embedded group structs.
-func (c *Client) QueryUsersWithGroups() ([]User, error) {
+// QueryUsersWithGroups queries users including embedded group structs. Depending on the membership
+// mode the groups are read from the memberOf attribute of the users or from the members of the groups.
+// Nested groups resolved with LDAP_MATCHING_RULE_IN_CHAIN are limited to the groups managed by ownership.
+func (c *Client) QueryUsersWithGroups(ownership policy.Ownership) ([]User, error) {
+	var users []User
+	var entryNames map[string]string
+	var err error
+	if c.config.Membership == MembershipGroup {
+		users, entryNames, err = c.queryGroupMembers()
+	} else {
+		users, err = c.queryMemberOf()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.resolveNestedGroups(users, ownership); err != nil {
+		return nil, err
+	}
+	if err := c.applyEntryNames(users, entryNames); err != nil {
+		return nil, err
+	}
+
+	c.log.Debug("Queried users with groups", "count", len(users))
+	return users, nil
+}
+
+// queryMemberOf queries users with the groups listed in their memberOf attribute.
+func (c *Client) queryMemberOf() ([]User, error) {
 	entries, err := c.searchEntries(c.config.UserFilter, c.makeUserAttrs(true))
 	if err != nil {
 		return nil, err
@@ -316,18 +395,19 @@ func (c *Client) QueryUsersWithGroups() ([]User, error) {
 	for _, entry := range entries {
 		users = append(users, c.mapEntryToUser(entry, true))
 	}
-
-	c.log.Debug("Queried users with groups", "count", len(users))
 	return users, nil
 }
 
 // searchEntries executes an LDAP search for the given filter and specified attributes.
+// Results are fetched in pages of the configured size (RFC 2696), a page size of 0 disables paging.
+// A result truncated by a server limit fails the search instead of returning a partial result,
+// attributes returned in ranges are read completely.
 func (c *Client) searchEntries(filter string, attrs []string) ([]*ldap.Entry, error) {
 	filter = wrapFilter(filter)
 	c.log.Debug("LDAP search filter", "filter", filter)
 	c.log.Debug("LDAP search baseDN", "baseDN", c.config.BaseDN)
 
-	sr, err := c.conn.Search(ldap.NewSearchRequest(
+	req := ldap.NewSearchRequest(
 		c.config.BaseDN,
 		ldap.ScopeWholeSubtree,
 		ldap.NeverDerefAliases,
@@ -337,23 +417,42 @@ func (c *Client) searchEntries(filter string, attrs []string) ([]*ldap.Entry, er
 		filter,
 		attrs,
 		nil,
-	))
+	)
+
+	start := time.Now()
+	var sr *ldap.SearchResult
+	var err error
+	if c.config.PageSize > 0 {
+		sr, err = c.conn.SearchWithPaging(req, uint32(c.config.PageSize))
+	} else {
+		sr, err = c.conn.Search(req)
+	}
+	metrics.LdapSearchDuration.WithLabelValues(metrics.Outcome(err)).Observe(time.Since(start).Seconds())
 	if err != nil {
-		c.log.Error("LDAP search failed", "error", err)
+		received := 0
+		if sr != nil {
+			received = len(sr.Entries)
+		}
+		c.log.Error("LDAP search failed", "error", err, "received_entries", received)
+		if ldap.IsErrorAnyOf(err, ldap.LDAPResultSizeLimitExceeded, ldap.LDAPResultTimeLimitExceeded, ldap.LDAPResultAdminLimitExceeded) {
+			return nil, fmt.Errorf("LDAP search truncated by a server limit after %d entries, refusing a partial sync (check LDAP_PAGE_SIZE and the server limits): %w", received, err)
+		}
+		return nil, contextError(c.ctx, err)
+	}
+	metrics.LdapSearchEntries.Observe(float64(len(sr.Entries)))
+	if err := c.expandRangedAttributes(sr.Entries); err != nil {
 		return nil, err
 	}
 	return sr.Entries, nil
 }
 
-// extractCN extracts CN from a DN string.
-func extractCN(dn string) string {
-	for _, part := range strings.Split(dn, ",") {
-		p := strings.TrimSpace(part)
-		if strings.HasPrefix(strings.ToLower(p), "cn=") {
-			return p[3:]
-		}
+// contextError reports a cancelled or timed out ctx as the cause of err,
+// which otherwise only says that the connection was closed.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
 	}
-	return ""
+	return err
 }
 
 // wrapFilter ensures the filter is wrapped in an AND clause if needed.
@@ -0,0 +1,168 @@
+package ldap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Membership modes selecting where group memberships are read from
+const (
+	MembershipUser  = "user"
+	MembershipGroup = "group"
+)
+
+// uniqueMemberUID matches the optional UID suffix of a uniqueMember value (nameAndOptionalUID, RFC 4517)
+var uniqueMemberUID = regexp.MustCompile(`#'[01]*'B$`)
+
+// userIndex finds users by the different values group members are stored as.
+type userIndex struct {
+	byDN       map[string]int
+	byUID      map[string]int
+	byUsername map[string]int
+	byGID      map[string][]int
+}
+
+// newUserIndex indexes users by DN, UID, username and primary gidNumber.
+func newUserIndex(users []User) *userIndex {
+	idx := &userIndex{
+		byDN:       make(map[string]int, len(users)),
+		byUID:      make(map[string]int, len(users)),
+		byUsername: make(map[string]int, len(users)),
+		byGID:      make(map[string][]int),
+	}
+	for i, user := range users {
+		idx.byDN[dnKey(user.DN)] = i
+		if user.UID != "" {
+			idx.byUID[strings.ToLower(user.UID)] = i
+		}
+		if user.Username != "" {
+			idx.byUsername[strings.ToLower(user.Username)] = i
+		}
+		if user.GIDNumber != "" {
+			idx.byGID[user.GIDNumber] = append(idx.byGID[user.GIDNumber], i)
+		}
+	}
+	return idx
+}
+
+// member returns the user a member value refers to. DN values (member, uniqueMember) are matched
+// against the user DNs, any other value is treated as a username.
+func (idx *userIndex) member(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if dn := uniqueMemberUID.ReplaceAllString(value, ""); isDN(dn) {
+		i, ok := idx.byDN[dnKey(dn)]
+		return i, ok
+	}
+	return idx.memberUID(value)
+}
+
+// memberUID returns the user a plain username (RFC 2307 memberUid) refers to, matched against
+// the user UID first and the username second.
+func (idx *userIndex) memberUID(value string) (int, bool) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if i, ok := idx.byUID[key]; ok {
+		return i, true
+	}
+	i, ok := idx.byUsername[key]
+	return i, ok
+}
+
+// queryGroupMembers queries users and groups separately and joins the members of the groups back
+// to the users. Members are read from the member attribute (RFC 2307bis, DNs), the memberUid
+// attribute (RFC 2307, usernames) and the gidNumber of the users' primary group.
+// It also returns the names of the groups read from their entries by DN key.
+func (c *Client) queryGroupMembers() ([]User, map[string]string, error) {
+	users, err := c.QueryUsers()
+	if err != nil {
+		return nil, nil, err
+	}
+	groups, err := c.QueryGroups()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entryNames := make(map[string]string, len(groups))
+	for _, group := range groups {
+		entryNames[dnKey(group.DN)] = group.Name
+	}
+
+	idx := newUserIndex(users)
+	unmatched := 0
+	for _, group := range groups {
+		name := c.groupName(group.DN)
+		if name == "" {
+			c.log.Warn("Skipping group without name", "dn", group.DN)
+			continue
+		}
+
+		var members []int
+		for _, value := range group.Members {
+			if i, ok := idx.member(value); ok {
+				members = append(members, i)
+			} else {
+				unmatched++
+			}
+		}
+		for _, value := range group.MemberUIDs {
+			if i, ok := idx.memberUID(value); ok {
+				members = append(members, i)
+			} else {
+				unmatched++
+			}
+		}
+		if group.GIDNumber != "" {
+			members = append(members, idx.byGID[group.GIDNumber]...)
+		}
+
+		added := make(map[int]bool, len(members))
+		for _, i := range members {
+			if added[i] {
+				continue
+			}
+			added[i] = true
+			users[i].Groups = append(users[i].Groups, Group{Name: name, DN: group.DN})
+		}
+	}
+
+	c.log.Debug("Joined group members to users", "groups", len(groups), "users", len(users), "unmatched_members", unmatched)
+	return users, entryNames, nil
+}
+
+// applyEntryNames renames the groups of the users to the AttrGroupCN value of their group entries,
+// if enabled. Entries are read with the group filter unless entryNames is already known. Groups
+// without a matching entry keep the name taken from their DN.
+func (c *Client) applyEntryNames(users []User, entryNames map[string]string) error {
+	if !c.config.IsGroupNameFromEntry {
+		return nil
+	}
+
+	if entryNames == nil {
+		entries, err := c.searchEntries(c.config.GroupFilter, []string{c.AttrGroupCN})
+		if err != nil {
+			return fmt.Errorf("failed to read group names: %w", err)
+		}
+		entryNames = make(map[string]string, len(entries))
+		for _, entry := range entries {
+			entryNames[dnKey(entry.DN)] = entry.GetAttributeValue(c.AttrGroupCN)
+		}
+	}
+
+	missing := make(map[string]bool)
+	for i := range users {
+		for j := range users[i].Groups {
+			group := &users[i].Groups[j]
+			if name := entryNames[dnKey(group.DN)]; name != "" {
+				group.Name = name
+			} else {
+				missing[group.DN] = true
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		c.log.Debug("Groups without a named entry matching the group filter keep the name from their DN",
+			"attribute", c.AttrGroupCN, "groups", len(missing))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package ldap
+
+import (
+	"fmt"
+
+	"github.com/go-ldap/ldap/v3"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
+)
+
+// Nested group resolution modes
+const (
+	NestedNone      = "none"
+	NestedInChain   = "in_chain"
+	NestedRecursive = "recursive"
+)
+
+// matchingRuleInChain is LDAP_MATCHING_RULE_IN_CHAIN of Active Directory, which matches transitive members
+const matchingRuleInChain = "1.2.840.113556.1.4.1941"
+
+// noAttrs requests no attributes, only the DN of each entry (RFC 4511)
+var noAttrs = []string{"1.1"}
+
+// resolveNestedGroups adds the groups users belong to through other groups to their direct groups.
+func (c *Client) resolveNestedGroups(users []User, ownership policy.Ownership) error {
+	var (
+		inherited map[string][]string
+		err       error
+	)
+	switch c.config.NestedGroups {
+	case NestedInChain:
+		inherited, err = c.inChainMemberships(ownership)
+	case NestedRecursive:
+		inherited, err = c.recursiveMemberships(users)
+	default:
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve nested groups: %w", err)
+	}
+
+	for i := range users {
+		user := &users[i]
+		seen := make(map[string]bool, len(user.Groups))
+		for _, group := range user.Groups {
+			seen[dnKey(group.DN)] = true
+		}
+		for _, dn := range inherited[dnKey(user.DN)] {
+			if seen[dnKey(dn)] {
+				continue
+			}
+			seen[dnKey(dn)] = true
+			if name := c.groupName(dn); name != "" {
+				user.Groups = append(user.Groups, Group{Name: name, DN: dn})
+			}
+		}
+	}
+	return nil
+}
+
+// inChainMemberships asks Active Directory for the transitive members of the managed groups matching
+// the group filter and returns the group DNs per user DN. The server takes care of cycles and depth.
+// Other groups are not expanded, each one would cost a search and their members are never synced.
+func (c *Client) inChainMemberships(ownership policy.Ownership) (map[string][]string, error) {
+	attrs := noAttrs
+	if c.config.IsGroupNameFromEntry {
+		attrs = []string{c.AttrGroupCN}
+	}
+	entries, err := c.searchEntries(c.config.GroupFilter, attrs)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []*ldap.Entry
+	for _, entry := range entries {
+		name := c.groupName(entry.DN)
+		if c.config.IsGroupNameFromEntry {
+			if entryName := entry.GetAttributeValue(c.AttrGroupCN); entryName != "" {
+				name = entryName
+			}
+		}
+		if name != "" && ownership.Manages(name) {
+			groups = append(groups, entry)
+		}
+	}
+
+	memberships := make(map[string][]string)
+	for _, group := range groups {
+		filter := fmt.Sprintf("(&%s(%s:%s:=%s))",
+			wrapFilter(c.config.UserFilter), c.AttrUserMemberOf, matchingRuleInChain, ldap.EscapeFilter(group.DN))
+		members, err := c.searchEntries(filter, noAttrs)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			key := dnKey(member.DN)
+			memberships[key] = append(memberships[key], group.DN)
+		}
+	}
+
+	c.log.Debug("Resolved nested groups with LDAP_MATCHING_RULE_IN_CHAIN",
+		"groups", len(entries), "managed_groups", len(groups), "members", len(memberships))
+	return memberships, nil
+}
+
+// recursiveMemberships walks from the direct groups of every user to their parent groups, read from
+// the groups matching the group filter, and returns the group DNs per user DN. Cycles are skipped and
+// the walk stops at the configured maximum depth.
+func (c *Client) recursiveMemberships(users []User) (map[string][]string, error) {
+	entries, err := c.searchEntries(c.config.GroupFilter, []string{c.AttrGroupMember, c.AttrGroupMemberOf})
+	if err != nil {
+		return nil, err
+	}
+
+	// parents maps a group DN to the DNs of the groups containing it, from both sides of the relation
+	parents := make(map[string][]string)
+	addParent := func(child, parent string) {
+		key := dnKey(child)
+		for _, existing := range parents[key] {
+			if dnKey(existing) == dnKey(parent) {
+				return
+			}
+		}
+		parents[key] = append(parents[key], parent)
+	}
+	isGroup := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		isGroup[dnKey(entry.DN)] = true
+	}
+	for _, entry := range entries {
+		for _, parent := range entry.GetAttributeValues(c.AttrGroupMemberOf) {
+			addParent(entry.DN, parent)
+		}
+		for _, member := range entry.GetAttributeValues(c.AttrGroupMember) {
+			if isGroup[dnKey(member)] {
+				addParent(member, entry.DN)
+			}
+		}
+	}
+
+	memberships := make(map[string][]string)
+	for _, user := range users {
+		direct := make([]string, 0, len(user.Groups))
+		for _, group := range user.Groups {
+			direct = append(direct, group.DN)
+		}
+		if inherited := c.walkParents(direct, parents); len(inherited) > 0 {
+			memberships[dnKey(user.DN)] = inherited
+		}
+	}
+
+	c.log.Debug("Resolved nested groups recursively", "groups", len(entries), "members", len(memberships))
+	return memberships, nil
+}
+
+// walkParents returns the groups reachable from the direct groups, breadth first up to the maximum depth.
+func (c *Client) walkParents(direct []string, parents map[string][]string) []string {
+	visited := make(map[string]bool, len(direct))
+	for _, dn := range direct {
+		visited[dnKey(dn)] = true
+	}
+
+	var inherited []string
+	level := direct
+	for depth := 1; len(level) > 0; depth++ {
+		var next []string
+		for _, dn := range level {
+			for _, parent := range parents[dnKey(dn)] {
+				if visited[dnKey(parent)] {
+					continue
+				}
+				visited[dnKey(parent)] = true
+				next = append(next, parent)
+			}
+		}
+		if len(next) > 0 && depth > c.config.NestedMaxDepth {
+			c.log.Warn("Nested group depth limit reached, ignoring deeper groups",
+				"max_depth", c.config.NestedMaxDepth, "groups", next)
+			break
+		}
+		inherited = append(inherited, next...)
+		level = next
+	}
+	return inherited
+}
@@ -0,0 +1,65 @@
+package ldap
+
+import (
+	"io"
+	"slices"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+func newTestClient(t *testing.T, cfg config.LdapConfig) *Client {
+	t.Helper()
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	return &Client{config: cfg, log: log}
+}
+
+func TestWalkParents(t *testing.T) {
+	// a -> b -> c -> d -> e, with a cycle c -> a and a second parent b -> x
+	parents := map[string][]string{
+		dnKey("cn=a,dc=ex"): {"cn=b,dc=ex"},
+		dnKey("cn=b,dc=ex"): {"CN=C,DC=EX", "cn=x,dc=ex"},
+		dnKey("cn=c,dc=ex"): {"cn=d,dc=ex", "cn=a,dc=ex"},
+		dnKey("cn=d,dc=ex"): {"cn=e,dc=ex"},
+	}
+
+	tests := map[string]struct {
+		maxDepth int
+		direct   []string
+		want     []string
+	}{
+		"full depth": {
+			maxDepth: 10,
+			direct:   []string{"cn=a,dc=ex"},
+			want:     []string{"cn=b,dc=ex", "CN=C,DC=EX", "cn=x,dc=ex", "cn=d,dc=ex", "cn=e,dc=ex"},
+		},
+		"depth limit": {
+			maxDepth: 2,
+			direct:   []string{"cn=a,dc=ex"},
+			want:     []string{"cn=b,dc=ex", "CN=C,DC=EX", "cn=x,dc=ex"},
+		},
+		"direct groups are not repeated": {
+			maxDepth: 10,
+			direct:   []string{"cn=a,dc=ex", "cn=d,dc=ex"},
+			want:     []string{"cn=b,dc=ex", "cn=e,dc=ex", "CN=C,DC=EX", "cn=x,dc=ex"},
+		},
+		"no parents": {
+			maxDepth: 10,
+			direct:   []string{"cn=e,dc=ex"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := newTestClient(t, config.LdapConfig{NestedMaxDepth: tt.maxDepth})
+			got := c.walkParents(tt.direct, parents)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("walkParents() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package ldap
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// rangeEnd marks the last part of a ranged attribute, `member;range=1500-*`
+const rangeEnd = -1
+
+// rangedAttribute matches an attribute Active Directory returned only partially, e.g. `member;range=0-1499`
+var rangedAttribute = regexp.MustCompile(`(?i)^(.+);range=(\d+)-(\d+|\*)$`)
+
+// parseRange splits a ranged attribute name into the attribute and its value range.
+// The end is rangeEnd for the last part.
+func parseRange(name string) (attr string, start, end int, ok bool) {
+	m := rangedAttribute.FindStringSubmatch(name)
+	if m == nil {
+		return "", 0, 0, false
+	}
+	start, err := strconv.Atoi(m[2])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	end = rangeEnd
+	if m[3] != "*" {
+		if end, err = strconv.Atoi(m[3]); err != nil || end < start {
+			return "", 0, 0, false
+		}
+	}
+	return m[1], start, end, true
+}
+
+// expandRangedAttributes reads the remaining values of attributes that were returned in parts
+// (Active Directory range retrieval, used for more than MaxValRange values, 1500 by default) and
+// stores all values under the plain attribute name, so groups with many members are complete.
+func (c *Client) expandRangedAttributes(entries []*ldap.Entry) error {
+	for _, entry := range entries {
+		for i, attr := range entry.Attributes {
+			name, _, end, ok := parseRange(attr.Name)
+			if !ok {
+				continue
+			}
+
+			values := attr.Values
+			if end != rangeEnd {
+				rest, err := c.fetchRanges(entry.DN, name, end+1)
+				if err != nil {
+					return err
+				}
+				values = append(values[:len(values):len(values)], rest...)
+				c.log.Debug("Read ranged attribute", "dn", entry.DN, "attribute", name, "values", len(values))
+			}
+
+			byteValues := make([][]byte, len(values))
+			for j, value := range values {
+				byteValues[j] = []byte(value)
+			}
+			entry.Attributes[i] = &ldap.EntryAttribute{Name: name, Values: values, ByteValues: byteValues}
+		}
+	}
+	return nil
+}
+
+// fetchRanges reads the values of attr on the entry dn from start up to the last range.
+func (c *Client) fetchRanges(dn, attr string, start int) ([]string, error) {
+	var values []string
+	for {
+		req := ldap.NewSearchRequest(
+			dn,
+			ldap.ScopeBaseObject,
+			ldap.NeverDerefAliases,
+			0,
+			0,
+			false,
+			"(objectClass=*)",
+			[]string{fmt.Sprintf("%s;range=%d-*", attr, start)},
+			nil,
+		)
+		sr, err := c.conn.Search(req)
+		if err != nil {
+			c.log.Error("LDAP ranged attribute search failed", "dn", dn, "attribute", attr, "error", err)
+			return nil, fmt.Errorf("failed to read %s of %s from value %d: %w", attr, dn, start, contextError(c.ctx, err))
+		}
+		if len(sr.Entries) != 1 {
+			return nil, fmt.Errorf("failed to read %s of %s from value %d: entry not found", attr, dn, start)
+		}
+
+		next := -1
+		for _, part := range sr.Entries[0].Attributes {
+			name, from, end, ok := parseRange(part.Name)
+			if !ok || !strings.EqualFold(name, attr) {
+				continue
+			}
+			if from != start {
+				return nil, fmt.Errorf("failed to read %s of %s: requested values from %d, got %s", attr, dn, start, part.Name)
+			}
+			values = append(values, part.Values...)
+			if end == rangeEnd {
+				return values, nil
+			}
+			next = end + 1
+		}
+		if next < 0 {
+			return nil, fmt.Errorf("failed to read %s of %s: no values returned from %d, refusing a partial sync", attr, dn, start)
+		}
+		start = next
+	}
+}
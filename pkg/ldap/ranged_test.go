@@ -0,0 +1,27 @@
+package ldap
+
+import "testing"
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		attr       string
+		start, end int
+		ok         bool
+	}{
+		{"member;range=0-1499", "member", 0, 1499, true},
+		{"member;range=1500-*", "member", 1500, rangeEnd, true},
+		{"Member;Range=3000-3999", "Member", 3000, 3999, true},
+		{"member", "", 0, 0, false},
+		{"member;range=10-5", "", 0, 0, false},
+		{"member;range=a-*", "", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		attr, start, end, ok := parseRange(tt.name)
+		if attr != tt.attr || start != tt.start || end != tt.end || ok != tt.ok {
+			t.Errorf("parseRange(%q) = %q, %d, %d, %v, want %q, %d, %d, %v",
+				tt.name, attr, start, end, ok, tt.attr, tt.start, tt.end, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"context"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+)
+
+// Source implements source.IdentitySource using a fresh LDAP connection per fetch.
+type Source struct {
+	config    config.LdapConfig
+	ownership policy.Ownership
+	log       logger.ILogger
+}
+
+var (
+	_ source.IdentitySource = (*Source)(nil)
+	_ source.Pinger         = (*Source)(nil)
+)
+
+// NewSource creates an LDAP identity source with config, the ownership of the synced groups and logger.
+func NewSource(cfg config.LdapConfig, ownership policy.Ownership, log logger.ILogger) *Source {
+	return &Source{
+		config:    cfg,
+		ownership: ownership,
+		log:       log,
+	}
+}
+
+// Name returns the source identifier.
+func (s *Source) Name() string {
+	return "ldap"
+}
+
+// FetchIdentities queries users with their groups and normalizes them.
+func (s *Source) FetchIdentities(ctx context.Context) ([]source.Identity, error) {
+	s.log.Debug("Starting LDAP client setup...")
+	client, err := NewClient(ctx, s.config, s.log)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	s.log.Debug("LDAP client setup complete")
+
+	users, err := client.QueryUsersWithGroups(s.ownership)
+	if err != nil {
+		return nil, err
+	}
+
+	identities := make([]source.Identity, 0, len(users))
+	for _, user := range users {
+		identities = append(identities, user.ToIdentity())
+	}
+	return identities, nil
+}
+
+// Ping connects and binds to the LDAP server to check that it is reachable.
+func (s *Source) Ping(ctx context.Context) error {
+	client, err := NewClient(ctx, s.config, s.log)
+	if err != nil {
+		return err
+	}
+	client.Close()
+	return nil
+}
@@ -1,5 +1,7 @@
 package ldap
 
+import "hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+
 // User represents an LDAP user with full details.
 type User struct {
 	UID           string
@@ -13,6 +15,7 @@ type User struct {
 	HomeDirectory string
 	LoginShell    string
 	Manager       string
+	GIDNumber     string
 	MemberOf      []string
 	WhenChanged   string
 	Info          string
@@ -27,3 +30,17 @@ func (u *User) GetAttribute(attr string) string {
 	}
 	return ""
 }
+
+// ToIdentity converts the user to a source.Identity with the names of its groups.
+func (u *User) ToIdentity() source.Identity {
+	groups := make([]string, 0, len(u.Groups))
+	for _, group := range u.Groups {
+		groups = append(groups, group.Name)
+	}
+
+	return source.Identity{
+		Username: u.Username,
+		Email:    u.Email,
+		Groups:   groups,
+	}
+}
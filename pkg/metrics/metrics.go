@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric of the application
+const namespace = "headscale_oidc_sync"
+
+// Sync run metrics
+var (
+	SyncRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_runs_total",
+		Help:      "Sync runs by source and outcome, including skipped and cancelled runs.",
+	}, []string{"source", "outcome"})
+
+	SyncDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_duration_seconds",
+		Help:      "Duration of sync runs.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"source"})
+
+	LastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful sync.",
+	}, []string{"source"})
+
+	GroupsGenerated = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "groups_generated",
+		Help:      "Groups in the policy after the last sync.",
+	}, []string{"source"})
+
+	MembersGenerated = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "members_generated",
+		Help:      "Unique group members in the policy after the last sync.",
+	}, []string{"source"})
+)
+
+// LDAP metrics
+var (
+	LdapSearchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ldap_search_duration_seconds",
+		Help:      "Latency of LDAP searches by outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"outcome"})
+
+	LdapSearchEntries = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ldap_search_entries",
+		Help:      "Entries returned by LDAP searches.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+	})
+)
+
+// Policy sink metrics
+var (
+	PolicyWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "policy_writes_total",
+		Help:      "Policy writes by sink and outcome.",
+	}, []string{"sink", "outcome"})
+
+	ReloadAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reload_attempts_total",
+		Help:      "Headscale reload attempts by strategy.",
+	}, []string{"strategy"})
+
+	ReloadFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reload_failures_total",
+		Help:      "Failed Headscale reloads, including rejected policies, by strategy.",
+	}, []string{"strategy"})
+)
+
+// Webhook metrics
+var (
+	WebhookEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_events_total",
+		Help:      "Received webhook events by payload format and outcome.",
+	}, []string{"format", "outcome"})
+)
+
+// Outcome label values
+const (
+	OutcomeSuccess   = "success"
+	OutcomeFailure   = "failure"
+	OutcomeCancelled = "cancelled"
+	OutcomeSkipped   = "skipped"
+	OutcomeQueued    = "queued"
+	OutcomeAccepted  = "accepted"
+	OutcomeIgnored   = "ignored"
+	OutcomeRejected  = "rejected"
+)
+
+// Outcome returns the outcome label value for err. Cancelled and timed out operations are told apart from failures.
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeSuccess
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return OutcomeCancelled
+	default:
+		return OutcomeFailure
+	}
+}
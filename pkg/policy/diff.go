@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Group change statuses
+const (
+	GroupAdded    = "added"
+	GroupRemoved  = "removed"
+	GroupModified = "modified"
+)
+
+// GroupChange describes how the members of a single group changed.
+type GroupChange struct {
+	Group   string   `json:"group"`
+	Status  string   `json:"status"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// GroupDiff lists the changed groups sorted by group key.
+type GroupDiff []GroupChange
+
+// DiffGroups compares two groups maps and returns the per-group member changes.
+func DiffGroups(before, after map[string][]string) GroupDiff {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var diff GroupDiff
+	for _, key := range sorted {
+		oldMembers, inBefore := before[key]
+		newMembers, inAfter := after[key]
+
+		change := GroupChange{
+			Group:   key,
+			Added:   missingFrom(oldMembers, newMembers),
+			Removed: missingFrom(newMembers, oldMembers),
+		}
+		switch {
+		case !inBefore:
+			change.Status = GroupAdded
+		case !inAfter:
+			change.Status = GroupRemoved
+		case len(change.Added) > 0 || len(change.Removed) > 0:
+			change.Status = GroupModified
+		default:
+			continue
+		}
+		diff = append(diff, change)
+	}
+
+	return diff
+}
+
+// IsEmpty reports whether no group changed.
+func (d GroupDiff) IsEmpty() bool {
+	return len(d) == 0
+}
+
+// Print writes a human-readable summary of the changes.
+func (d GroupDiff) Print(w io.Writer) {
+	if d.IsEmpty() {
+		fmt.Fprintln(w, "No group changes")
+		return
+	}
+
+	for _, change := range d {
+		marker := "~"
+		switch change.Status {
+		case GroupAdded:
+			marker = "+"
+		case GroupRemoved:
+			marker = "-"
+		}
+		fmt.Fprintf(w, "%s %s\n", marker, change.Group)
+		for _, member := range change.Added {
+			fmt.Fprintf(w, "    + %s\n", member)
+		}
+		for _, member := range change.Removed {
+			fmt.Fprintf(w, "    - %s\n", member)
+		}
+	}
+}
+
+// missingFrom returns the sorted members of list that are not in base.
+func missingFrom(base, list []string) []string {
+	seen := make(map[string]bool, len(base))
+	for _, member := range base {
+		seen[member] = true
+	}
+
+	var missing []string
+	for _, member := range list {
+		if !seen[member] {
+			seen[member] = true
+			missing = append(missing, member)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffGroups(t *testing.T) {
+	before := map[string][]string{
+		"group:a": {"alice@", "bob@"},
+		"group:b": {"carol@"},
+		"group:c": {"dave@"},
+	}
+	after := map[string][]string{
+		"group:a": {"bob@", "erin@"},
+		"group:c": {"dave@"},
+		"group:d": {"frank@"},
+	}
+
+	want := GroupDiff{
+		{Group: "group:a", Status: GroupModified, Added: []string{"erin@"}, Removed: []string{"alice@"}},
+		{Group: "group:b", Status: GroupRemoved, Removed: []string{"carol@"}},
+		{Group: "group:d", Status: GroupAdded, Added: []string{"frank@"}},
+	}
+	if got := DiffGroups(before, after); !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffGroups() = %+v\nwant %+v", got, want)
+	}
+
+	if diff := DiffGroups(before, before); !diff.IsEmpty() {
+		t.Errorf("DiffGroups of equal maps = %+v, want empty", diff)
+	}
+	if diff := DiffGroups(map[string][]string{"group:e": {}}, nil); len(diff) != 1 || diff[0].Status != GroupRemoved {
+		t.Errorf("removing an empty group = %+v, want removed", diff)
+	}
+}
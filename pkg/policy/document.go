@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// Top-level policy sections used by the sync
+const (
+	SectionGroups = "groups"
+	SectionACLs   = "acls"
+)
+
+// defaultIndent is used when the document has no indented top-level keys
+const defaultIndent = "  "
+
+// Document is a HuJSON policy that keeps comments, trailing commas, key order
+// and formatting of everything that is not explicitly replaced.
+type Document struct {
+	root hujson.Value
+}
+
+// Parse parses a HuJSON (or plain JSON) policy document.
+// An empty input is treated as an empty policy.
+func Parse(data []byte) (*Document, error) {
+	if len(bytes.TrimSpace(data)) == 0 {
+		data = []byte("{}")
+	}
+
+	root, err := hujson.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if _, ok := root.Value.(*hujson.Object); !ok {
+		return nil, errors.New("failed to parse policy: top-level value is not an object")
+	}
+
+	return &Document{root: root}, nil
+}
+
+// Bytes returns the serialized document.
+func (d *Document) Bytes() []byte {
+	return d.root.Pack()
+}
+
+// Decode unmarshals a top-level section into out, ignoring comments and trailing commas.
+// It reports whether the section exists.
+func (d *Document) Decode(name string, out any) (bool, error) {
+	member := d.member(name)
+	if member == nil {
+		return false, nil
+	}
+
+	value := member.Value.Clone()
+	value.Standardize()
+	if err := json.Unmarshal(value.Pack(), out); err != nil {
+		return true, fmt.Errorf("failed to decode policy section %q: %w", name, err)
+	}
+	return true, nil
+}
+
+// Groups returns the content of the groups section.
+func (d *Document) Groups() (map[string][]string, error) {
+	groups := make(map[string][]string)
+	if _, err := d.Decode(SectionGroups, &groups); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// SetGroups updates the groups section in place, inserting the section if it is missing.
+// Only changed groups are touched: their member lists are replaced, new groups are appended in
+// key order and removed groups are deleted. Unchanged groups keep their comments, formatting and position.
+func (d *Document) SetGroups(groups map[string][]string) error {
+	if groups == nil {
+		groups = make(map[string][]string)
+	}
+
+	existing, err := d.Groups()
+	if err == nil && d.member(SectionGroups) != nil && reflect.DeepEqual(existing, groups) {
+		return nil
+	}
+
+	if member := d.member(SectionGroups); member != nil {
+		if obj, ok := member.Value.Value.(*hujson.Object); ok && len(obj.Members) > 0 {
+			if err := d.updateGroups(obj, groups); err != nil {
+				return err
+			}
+			d.root.UpdateOffsets()
+			return nil
+		}
+	}
+
+	indent := d.indent()
+	data, err := json.MarshalIndent(groups, indent, indent)
+	if err != nil {
+		return fmt.Errorf("failed to marshal groups: %w", err)
+	}
+	value, err := hujson.Parse(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse generated groups: %w", err)
+	}
+
+	if member := d.member(SectionGroups); member != nil {
+		member.Value.Value = value.Value
+	} else {
+		// The groups are inserted as the first member on their own line, so the other top-level
+		// members and the closing brace of a compact document start on a new line as well
+		obj := d.object()
+		if !bytes.Contains(obj.AfterExtra, []byte("\n")) {
+			obj.AfterExtra = append(hujson.Extra("\n"), bytes.TrimLeft(obj.AfterExtra, " \t")...)
+		}
+		for i := range obj.Members {
+			name := &obj.Members[i].Name
+			if !bytes.Contains(name.BeforeExtra, []byte("\n")) {
+				name.BeforeExtra = append(hujson.Extra("\n"+indent), bytes.TrimLeft(name.BeforeExtra, " \t")...)
+			}
+		}
+		member := hujson.ObjectMember{
+			Name:  hujson.Value{BeforeExtra: hujson.Extra("\n" + indent), Value: hujson.String(SectionGroups)},
+			Value: hujson.Value{BeforeExtra: hujson.Extra(" "), Value: value.Value},
+		}
+		obj.Members = append([]hujson.ObjectMember{member}, obj.Members...)
+	}
+
+	d.root.UpdateOffsets()
+	return nil
+}
+
+// object returns the top-level object of the document.
+func (d *Document) object() *hujson.Object {
+	return d.root.Value.(*hujson.Object)
+}
+
+// member returns the top-level member with the given name, or nil if it does not exist.
+func (d *Document) member(name string) *hujson.ObjectMember {
+	obj := d.object()
+	for i := range obj.Members {
+		if lit, ok := obj.Members[i].Name.Value.(hujson.Literal); ok && lit.String() == name {
+			return &obj.Members[i]
+		}
+	}
+	return nil
+}
+
+// indent detects the indentation of the top-level keys.
+func (d *Document) indent() string {
+	for _, member := range d.object().Members {
+		extra := string(member.Name.BeforeExtra)
+		if i := strings.LastIndex(extra, "\n"); i >= 0 {
+			if ws := extra[i+1:]; ws != "" && strings.TrimSpace(ws) == "" {
+				return ws
+			}
+		}
+	}
+	return defaultIndent
+}
@@ -0,0 +1,197 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const huPolicy = `{
+  // Managed by the sync
+  "groups": {
+    "group:headscale-admins": ["alice@example.com"],
+    "group:ops": ["bob@"], // kept by hand
+  },
+  /* access rules */
+  "acls": [
+    {"action": "accept", "src": ["group:headscale-admins"], "dst": ["*:*"]},
+  ],
+}
+`
+
+func TestParseSetGroupsRoundTrip(t *testing.T) {
+	doc, err := Parse([]byte(huPolicy))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	groups, err := doc.Groups()
+	if err != nil {
+		t.Fatalf("Groups: %v", err)
+	}
+	want := map[string][]string{
+		"group:headscale-admins": {"alice@example.com"},
+		"group:ops":              {"bob@"},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("Groups() = %v, want %v", groups, want)
+	}
+
+	// Unchanged groups leave the document byte for byte as it was
+	if err := doc.SetGroups(groups); err != nil {
+		t.Fatalf("SetGroups: %v", err)
+	}
+	if got := string(doc.Bytes()); got != huPolicy {
+		t.Errorf("unchanged SetGroups rewrote the document:\n%s", got)
+	}
+
+	updated := map[string][]string{
+		"group:headscale-admins": {"alice@example.com", "carol@example.com"},
+		"group:ops":              {"bob@"},
+	}
+	if err := doc.SetGroups(updated); err != nil {
+		t.Fatalf("SetGroups: %v", err)
+	}
+	out := doc.Bytes()
+	for _, kept := range []string{"// Managed by the sync", "/* access rules */", `"src": ["group:headscale-admins"]`, `"group:ops": ["bob@"], // kept by hand`} {
+		if !strings.Contains(string(out), kept) {
+			t.Errorf("output lost %q:\n%s", kept, out)
+		}
+	}
+
+	reparsed, err := Parse(out)
+	if err != nil {
+		t.Fatalf("Parse(output): %v", err)
+	}
+	got, err := reparsed.Groups()
+	if err != nil {
+		t.Fatalf("Groups: %v", err)
+	}
+	if !reflect.DeepEqual(got, updated) {
+		t.Errorf("Groups() after round trip = %v, want %v", got, updated)
+	}
+	original, err := Parse([]byte(huPolicy))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := VerifyPreserved(original, reparsed, SectionGroups); err != nil {
+		t.Errorf("VerifyPreserved: %v", err)
+	}
+}
+
+func TestSetGroupsEditsChangedGroupsOnly(t *testing.T) {
+	in := `{
+  "groups": {
+    // Emergency access, do not remove
+    "group:breakglass": ["root@"],
+    "group:headscale-admins": ["alice@example.com"], // admins
+    "group:headscale-old": [
+      "old@",
+    ], // retired
+    "group:headscale-dev": [
+      "carol@",
+    ],
+  },
+  "acls": [],
+}`
+	want := `{
+  "groups": {
+    // Emergency access, do not remove
+    "group:breakglass": ["root@"],
+    "group:headscale-admins": ["alice@example.com"], // admins
+    "group:headscale-dev": [
+      "carol@",
+      "dave@"
+    ],
+    "group:headscale-new": ["erin@"],
+    "group:headscale-ops": [],
+  },
+  "acls": [],
+}`
+
+	doc, err := Parse([]byte(in))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	err = doc.SetGroups(map[string][]string{
+		"group:breakglass":       {"root@"},
+		"group:headscale-admins": {"alice@example.com"},
+		"group:headscale-dev":    {"carol@", "dave@"},
+		"group:headscale-new":    {"erin@"},
+		"group:headscale-ops":    {},
+	})
+	if err != nil {
+		t.Fatalf("SetGroups: %v", err)
+	}
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("SetGroups output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSetGroupsCompactGroups(t *testing.T) {
+	doc, err := Parse([]byte(`{"groups": {"group:a": ["x@"], "group:b": ["y@"]}, "acls": []}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if err := doc.SetGroups(map[string][]string{"group:b": {"y@", "z@"}, "group:c": {}}); err != nil {
+		t.Fatalf("SetGroups: %v", err)
+	}
+
+	want := `{"groups": {"group:b": ["y@", "z@"], "group:c": []}, "acls": []}`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("SetGroups output = %s, want %s", got, want)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, in := range []string{"", "  \n"} {
+		doc, err := Parse([]byte(in))
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", in, err)
+		}
+		if groups, _ := doc.Groups(); len(groups) != 0 {
+			t.Errorf("Parse(%q) groups = %v, want none", in, groups)
+		}
+	}
+
+	for _, in := range []string{`[]`, `{"groups": `, `"policy"`} {
+		if _, err := Parse([]byte(in)); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestSetGroupsInsertsIntoCompactDocument(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want string
+	}{
+		"empty": {
+			in:   `{}`,
+			want: "{\n  \"groups\": {\n    \"group:a\": [\n      \"x@\"\n    ]\n  }\n}",
+		},
+		"one line": {
+			in:   `{"acls": [], "hosts": {}}`,
+			want: "{\n  \"groups\": {\n    \"group:a\": [\n      \"x@\"\n    ]\n  },\n  \"acls\": [],\n  \"hosts\": {}\n}",
+		},
+		"indented": {
+			in:   "{\n\t\"acls\": [] // rules\n}",
+			want: "{\n\t\"groups\": {\n\t\t\"group:a\": [\n\t\t\t\"x@\"\n\t\t]\n\t},\n\t\"acls\": [] // rules\n}",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if err := doc.SetGroups(map[string][]string{"group:a": {"x@"}}); err != nil {
+				t.Fatalf("SetGroups: %v", err)
+			}
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("SetGroups output:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/tailscale/hujson"
+)
+
+// groupsLayout describes how the members of an existing groups object are written.
+type groupsLayout struct {
+	// multiline is set when every group starts on its own line
+	multiline bool
+	// keyIndent is the indentation of the group keys
+	keyIndent string
+	// elemIndent is the indentation of the members of a multi-line group
+	elemIndent string
+	// colon is the whitespace between the colon and the member list
+	colon hujson.Extra
+	// expanded is set when new groups list their members one per line
+	expanded bool
+}
+
+// updateGroups edits the existing groups object to contain exactly groups. Comments and formatting
+// of unchanged groups are kept, comments attached to a removed group are removed with it.
+func (d *Document) updateGroups(obj *hujson.Object, groups map[string][]string) error {
+	layout := d.groupsLayout(obj)
+	lastKey, _ := memberName(&obj.Members[len(obj.Members)-1])
+	lastExtra := obj.Members[len(obj.Members)-1].Value.AfterExtra
+	hadTrailingComma := setTrailingComma(obj, false)
+
+	// before returns the extra preceding the member at i, the closing brace for the last member
+	before := func(i int) *hujson.Extra {
+		if i < len(obj.Members) {
+			return &obj.Members[i].Name.BeforeExtra
+		}
+		return &obj.AfterExtra
+	}
+
+	seen := make(map[string]bool, len(groups))
+	for i := 0; i < len(obj.Members); {
+		member := &obj.Members[i]
+		key, ok := memberName(member)
+		members, keep := groups[key]
+		if !ok || seen[key] {
+			i++
+			continue
+		}
+		seen[key] = true
+
+		if !keep {
+			next := before(i + 1)
+			if layout.multiline {
+				// Drop the comment trailing the removed group, keep the one trailing the previous group
+				own := member.Name.BeforeExtra
+				*next = concatExtra(lineHead(own), lineRest(*next))
+			} else if i+1 < len(obj.Members) {
+				*next = member.Name.BeforeExtra
+			}
+			obj.Members = slices.Delete(obj.Members, i, i+1)
+			continue
+		}
+
+		var current []string
+		value := member.Value.Clone()
+		value.Standardize()
+		if json.Unmarshal(value.Pack(), &current) != nil || !slices.Equal(current, members) {
+			expanded := spansLines(member.Value.Value)
+			list, err := layout.memberList(members, expanded)
+			if err != nil {
+				return err
+			}
+			member.Value.Value = list
+		}
+		i++
+	}
+
+	var added []string
+	for key := range groups {
+		if !seen[key] {
+			added = append(added, key)
+		}
+	}
+	sort.Strings(added)
+	for _, key := range added {
+		list, err := layout.memberList(groups[key], layout.expanded)
+		if err != nil {
+			return err
+		}
+
+		nameExtra := hujson.Extra(" ")
+		if layout.multiline {
+			// The comment trailing the previous last group stays on its line
+			nameExtra = concatExtra(lineHead(obj.AfterExtra), hujson.Extra("\n"+layout.keyIndent))
+			obj.AfterExtra = lineRest(obj.AfterExtra)
+		}
+		obj.Members = append(obj.Members, hujson.ObjectMember{
+			Name:  hujson.Value{BeforeExtra: nameExtra, Value: hujson.String(key)},
+			Value: hujson.Value{BeforeExtra: slices.Clone(layout.colon), Value: list},
+		})
+	}
+
+	// A last group left in place gets back exactly the extra it had, comments before its comma included
+	if n := len(obj.Members); n > 0 {
+		tail := &obj.Members[n-1]
+		if key, _ := memberName(tail); key == lastKey && bytes.HasPrefix(obj.AfterExtra, lastExtra) {
+			tail.Value.AfterExtra = lastExtra
+			obj.AfterExtra = obj.AfterExtra[len(lastExtra):]
+			return nil
+		}
+	}
+	setTrailingComma(obj, hadTrailingComma)
+	return nil
+}
+
+// groupsLayout detects the formatting of the existing groups.
+func (d *Document) groupsLayout(obj *hujson.Object) groupsLayout {
+	var layout groupsLayout
+	for _, member := range obj.Members {
+		extra := member.Name.BeforeExtra
+		if i := bytes.LastIndexByte(extra, '\n'); i >= 0 {
+			layout.multiline = true
+			layout.keyIndent = string(extra[i+1:])
+			break
+		}
+	}
+	layout.elemIndent = layout.keyIndent + d.indent()
+	layout.colon = slices.Clone(obj.Members[0].Value.BeforeExtra)
+
+	layout.expanded = layout.multiline
+	for _, member := range obj.Members {
+		if arr, ok := member.Value.Value.(*hujson.Array); ok && len(arr.Elements) > 0 {
+			layout.expanded = spansLines(arr)
+			break
+		}
+	}
+	return layout
+}
+
+// memberList formats a group member list either on one line or with one member per line.
+func (l groupsLayout) memberList(members []string, expanded bool) (hujson.ValueTrimmed, error) {
+	var b strings.Builder
+	b.WriteString("[")
+	for i, member := range members {
+		quoted, err := json.Marshal(member)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal group member: %w", err)
+		}
+		switch {
+		case expanded:
+			b.WriteString("\n" + l.elemIndent)
+		case i > 0:
+			b.WriteString(" ")
+		}
+		b.Write(quoted)
+		if i < len(members)-1 {
+			b.WriteString(",")
+		}
+	}
+	if expanded && len(members) > 0 {
+		b.WriteString("\n" + l.keyIndent)
+	}
+	b.WriteString("]")
+
+	value, err := hujson.Parse([]byte(b.String()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse generated group members: %w", err)
+	}
+	return value.Value, nil
+}
+
+// spansLines reports whether the value is written over more than one line.
+func spansLines(value hujson.ValueTrimmed) bool {
+	return bytes.Contains(hujson.Value{Value: value}.Pack(), []byte("\n"))
+}
+
+// memberName returns the key of an object member.
+func memberName(member *hujson.ObjectMember) (string, bool) {
+	lit, ok := member.Name.Value.(hujson.Literal)
+	if !ok {
+		return "", false
+	}
+	return lit.String(), true
+}
+
+// setTrailingComma adds or removes the comma after the last member and reports whether it was present.
+// Comments between the last value and a removed comma move in front of the closing brace.
+func setTrailingComma(obj *hujson.Object, enabled bool) bool {
+	if len(obj.Members) == 0 {
+		return false
+	}
+	last := &obj.Members[len(obj.Members)-1].Value
+	had := last.AfterExtra != nil
+	switch {
+	case enabled && !had:
+		last.AfterExtra = hujson.Extra{}
+	case !enabled && had:
+		obj.AfterExtra = concatExtra(last.AfterExtra, obj.AfterExtra)
+		last.AfterExtra = nil
+	}
+	return had
+}
+
+// lineHead returns the part of extra before its first newline, i.e. the comment trailing the previous value.
+func lineHead(extra hujson.Extra) hujson.Extra {
+	if i := bytes.IndexByte(extra, '\n'); i >= 0 {
+		return extra[:i]
+	}
+	return extra
+}
+
+// lineRest returns the part of extra from its first newline on.
+func lineRest(extra hujson.Extra) hujson.Extra {
+	if i := bytes.IndexByte(extra, '\n'); i >= 0 {
+		return extra[i:]
+	}
+	return nil
+}
+
+// concatExtra joins extras into a new slice.
+func concatExtra(parts ...hujson.Extra) hujson.Extra {
+	var out hujson.Extra
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
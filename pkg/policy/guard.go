@@ -0,0 +1,60 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrRemovalLimitExceeded is returned when a sync would remove more groups or members than allowed.
+var ErrRemovalLimitExceeded = errors.New("removal limit exceeded")
+
+// RemovalLimits caps how much a single sync may remove. Zero disables a limit.
+type RemovalLimits struct {
+	MaxGroups         int
+	MaxGroupsPercent  int
+	MaxMembers        int
+	MaxMembersPercent int
+}
+
+// Check returns ErrRemovalLimitExceeded if the diff removes more than allowed from before.
+// Only groups managed according to ownership are counted, so hand-written groups do not dilute the percentages.
+func (l RemovalLimits) Check(before map[string][]string, diff GroupDiff, ownership Ownership) error {
+	removedGroups, removedMembers := 0, 0
+	for _, change := range diff {
+		if !ownership.Manages(change.Group) {
+			continue
+		}
+		if change.Status == GroupRemoved {
+			removedGroups++
+		}
+		removedMembers += len(change.Removed)
+	}
+
+	totalGroups, totalMembers := 0, 0
+	for group, members := range before {
+		if ownership.Manages(group) {
+			totalGroups++
+			totalMembers += len(members)
+		}
+	}
+
+	if err := checkLimit("groups", removedGroups, totalGroups, l.MaxGroups, l.MaxGroupsPercent); err != nil {
+		return err
+	}
+	return checkLimit("members", removedMembers, totalMembers, l.MaxMembers, l.MaxMembersPercent)
+}
+
+// checkLimit compares the removed count against an absolute and a percentage limit.
+func checkLimit(what string, removed, total, maxCount, maxPercent int) error {
+	if removed == 0 {
+		return nil
+	}
+	if maxCount > 0 && removed > maxCount {
+		return fmt.Errorf("%w: %d %s removed, maximum is %d", ErrRemovalLimitExceeded, removed, what, maxCount)
+	}
+	if maxPercent > 0 && total > 0 && removed*100 > total*maxPercent {
+		return fmt.Errorf("%w: %d of %d %s removed (%d%%), maximum is %d%%",
+			ErrRemovalLimitExceeded, removed, total, what, removed*100/total, maxPercent)
+	}
+	return nil
+}
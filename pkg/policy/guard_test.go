@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRemovalLimitsCheck(t *testing.T) {
+	// The unmanaged groups would halve both percentages if they were counted
+	ownership := Ownership{Prefix: "hs-", Groups: []string{"group:d"}}
+	before := map[string][]string{
+		"group:hs-a":   {"1@", "2@", "3@", "4@"},
+		"group:hs-b":   {"5@", "6@", "7@", "8@"},
+		"group:hs-c":   {"9@", "10@"},
+		"group:d":      {"11@", "12@"},
+		"group:manual": {"m1@", "m2@", "m3@", "m4@", "m5@", "m6@"},
+		"group:ops":    {"o1@", "o2@", "o3@", "o4@", "o5@", "o6@"},
+		"group:x":      {},
+		"group:y":      {},
+	}
+	removeGroup := GroupDiff{{Group: "group:hs-c", Status: GroupRemoved, Removed: []string{"9@", "10@"}}}
+	removeMembers := GroupDiff{
+		{Group: "group:hs-a", Status: GroupModified, Removed: []string{"1@", "2@", "3@"}},
+		{Group: "group:hs-b", Status: GroupModified, Removed: []string{"5@", "6@", "7@"}},
+	}
+	unmanagedChanges := GroupDiff{
+		{Group: "group:manual", Status: GroupRemoved, Removed: []string{"m1@", "m2@", "m3@", "m4@", "m5@", "m6@"}},
+		{Group: "group:ops", Status: GroupModified, Removed: []string{"o1@"}},
+	}
+
+	tests := map[string]struct {
+		limits  RemovalLimits
+		diff    GroupDiff
+		wantErr bool
+	}{
+		"no limits":                 {RemovalLimits{}, removeMembers, false},
+		"nothing removed":           {RemovalLimits{MaxGroups: 1, MaxMembers: 1}, GroupDiff{{Group: "group:e", Status: GroupAdded}}, false},
+		"group count within":        {RemovalLimits{MaxGroups: 1}, removeGroup, false},
+		"group count exceeded":      {RemovalLimits{MaxGroups: 1}, append(GroupDiff{{Group: "group:d", Status: GroupRemoved}}, removeGroup...), true},
+		"group percent within":      {RemovalLimits{MaxGroupsPercent: 25}, removeGroup, false},
+		"group percent exceeded":    {RemovalLimits{MaxGroupsPercent: 20}, removeGroup, true},
+		"member count exceeded":     {RemovalLimits{MaxMembers: 5}, removeMembers, true},
+		"member percent exceeded":   {RemovalLimits{MaxMembersPercent: 40}, removeMembers, true},
+		"member percent at limit":   {RemovalLimits{MaxMembersPercent: 50}, removeMembers, false},
+		"member percent within":     {RemovalLimits{MaxMembersPercent: 50}, removeGroup, false},
+		"removed group members too": {RemovalLimits{MaxMembers: 1}, removeGroup, true},
+		"unmanaged changes ignored": {RemovalLimits{MaxGroups: 1, MaxGroupsPercent: 1, MaxMembers: 1, MaxMembersPercent: 1}, unmanagedChanges, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.limits.Check(before, tt.diff, ownership)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("Check() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrRemovalLimitExceeded) {
+				t.Errorf("Check() error = %v, want ErrRemovalLimitExceeded", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"slices"
+	"strings"
+)
+
+// GroupKeyPrefix is the prefix of every group key in the policy
+const GroupKeyPrefix = "group:"
+
+// Ownership decides which policy groups are managed by the sync.
+type Ownership struct {
+	// Prefix marks groups managed by the sync by name prefix.
+	Prefix string
+	// Groups lists additional managed group names.
+	Groups []string
+}
+
+// Manages reports whether the group with the given name or policy key is managed by the sync.
+func (o Ownership) Manages(name string) bool {
+	name = strings.TrimPrefix(name, GroupKeyPrefix)
+	if o.Prefix != "" && strings.HasPrefix(name, o.Prefix) {
+		return true
+	}
+	return slices.ContainsFunc(o.Groups, func(group string) bool {
+		return strings.TrimPrefix(group, GroupKeyPrefix) == name
+	})
+}
+
+// MergeGroups combines the existing policy groups with the generated ones.
+// Unmanaged groups are kept exactly as they are. Managed groups are replaced by the
+// generated members, or extended with them when mergeMembers is set. A managed group
+// that lost all its members is kept empty while the policy still references it, so
+// the rules using it stay valid and its former members lose their access.
+func MergeGroups(existing, generated map[string][]string, ownership Ownership, mergeMembers bool, referenced map[string]bool) map[string][]string {
+	merged := make(map[string][]string, len(existing)+len(generated))
+
+	for key, members := range existing {
+		switch {
+		case !ownership.Manages(key) || mergeMembers:
+			merged[key] = members
+		case referenced[key]:
+			merged[key] = []string{}
+		}
+	}
+
+	for key, members := range generated {
+		if !ownership.Manages(key) {
+			continue
+		}
+		if mergeMembers {
+			merged[key] = appendUnique(merged[key], members...)
+		} else {
+			merged[key] = members
+		}
+	}
+
+	return merged
+}
+
+// appendUnique appends the members not already present in list.
+func appendUnique(list []string, members ...string) []string {
+	for _, member := range members {
+		if !slices.Contains(list, member) {
+			list = append(list, member)
+		}
+	}
+	return list
+}
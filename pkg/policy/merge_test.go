@@ -0,0 +1,82 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnershipManages(t *testing.T) {
+	ownership := Ownership{Prefix: "headscale-", Groups: []string{"admins", "group:ops"}}
+
+	tests := map[string]bool{
+		"headscale-dev":       true,
+		"group:headscale-dev": true,
+		"admins":              true,
+		"group:admins":        true,
+		"ops":                 true,
+		"developers":          false,
+		"group:my-headscale-": false,
+	}
+	for name, want := range tests {
+		if got := ownership.Manages(name); got != want {
+			t.Errorf("Manages(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	if (Ownership{}).Manages("headscale-dev") {
+		t.Error("empty ownership manages a group")
+	}
+}
+
+func TestMergeGroups(t *testing.T) {
+	ownership := Ownership{Prefix: "hs-"}
+	existing := map[string][]string{
+		"group:manual":     {"root@"},
+		"group:hs-dev":     {"alice@", "old@"},
+		"group:hs-gone":    {"bob@"},
+		"group:hs-revoked": {"carol@"},
+	}
+	generated := map[string][]string{
+		"group:hs-dev": {"alice@", "dave@"},
+		"group:hs-new": {"erin@"},
+		"group:other":  {"mallory@"},
+	}
+	referenced := map[string]bool{"group:hs-revoked": true}
+
+	tests := map[string]struct {
+		mergeMembers bool
+		want         map[string][]string
+	}{
+		"replace": {
+			want: map[string][]string{
+				"group:manual":     {"root@"},
+				"group:hs-dev":     {"alice@", "dave@"},
+				"group:hs-new":     {"erin@"},
+				"group:hs-revoked": {},
+			},
+		},
+		"merge members": {
+			mergeMembers: true,
+			want: map[string][]string{
+				"group:manual":     {"root@"},
+				"group:hs-dev":     {"alice@", "old@", "dave@"},
+				"group:hs-gone":    {"bob@"},
+				"group:hs-new":     {"erin@"},
+				"group:hs-revoked": {"carol@"},
+			},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got := MergeGroups(existing, generated, ownership, tt.mergeMembers, referenced)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeGroups() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !reflect.DeepEqual(existing["group:hs-dev"], []string{"alice@", "old@"}) {
+		t.Errorf("MergeGroups modified the existing groups: %v", existing["group:hs-dev"])
+	}
+}
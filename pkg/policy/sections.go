@@ -0,0 +1,44 @@
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+
+	"github.com/tailscale/hujson"
+)
+
+// Sections returns the names of the top-level sections in document order.
+func (d *Document) Sections() []string {
+	obj := d.object()
+	names := make([]string, 0, len(obj.Members))
+	for _, member := range obj.Members {
+		if lit, ok := member.Name.Value.(hujson.Literal); ok {
+			names = append(names, lit.String())
+		}
+	}
+	return names
+}
+
+// Raw returns the serialized value of a top-level section including its comments, or nil if it does not exist.
+func (d *Document) Raw(name string) []byte {
+	member := d.member(name)
+	if member == nil {
+		return nil
+	}
+	return member.Value.Pack()
+}
+
+// VerifyPreserved checks that every section of before, except the managed ones,
+// exists in after with exactly the same content.
+func VerifyPreserved(before, after *Document, managed ...string) error {
+	for _, name := range before.Sections() {
+		if slices.Contains(managed, name) {
+			continue
+		}
+		if !bytes.Equal(before.Raw(name), after.Raw(name)) {
+			return fmt.Errorf("policy section %q would be modified or dropped", name)
+		}
+	}
+	return nil
+}
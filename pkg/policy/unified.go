@@ -0,0 +1,179 @@
+package policy
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// unifiedContext is the number of unchanged lines shown around each change
+const unifiedContext = 3
+
+// lineOp is a single line of an edit script.
+type lineOp struct {
+	kind byte // ' ' unchanged, '-' removed, '+' added
+	text string
+}
+
+// WriteUnifiedDiff writes a unified diff between two documents.
+// Nothing is written when the documents are equal.
+func WriteUnifiedDiff(w io.Writer, fromName, toName string, from, to []byte) {
+	ops := diffLines(splitLines(string(from)), splitLines(string(to)))
+
+	hasChanges := false
+	for _, op := range ops {
+		if op.kind != ' ' {
+			hasChanges = true
+			break
+		}
+	}
+	if !hasChanges {
+		return
+	}
+
+	fmt.Fprintf(w, "--- %s\n", fromName)
+	fmt.Fprintf(w, "+++ %s\n", toName)
+
+	// Line numbers (1-based) of each op in the old and new document
+	oldLine, newLine := make([]int, len(ops)), make([]int, len(ops))
+	a, b := 1, 1
+	for i, op := range ops {
+		oldLine[i], newLine[i] = a, b
+		if op.kind != '+' {
+			a++
+		}
+		if op.kind != '-' {
+			b++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		// Extend the hunk while changes are closer than twice the context
+		start := max(i-unifiedContext, 0)
+		end := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].kind != ' ' {
+				end = j
+			} else if j-end > 2*unifiedContext {
+				break
+			}
+		}
+		end = min(end+unifiedContext, len(ops)-1)
+
+		oldCount, newCount := 0, 0
+		for _, op := range ops[start : end+1] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+
+		fmt.Fprintf(w, "@@ -%s +%s @@\n",
+			hunkRange(oldLine[start], oldCount), hunkRange(newLine[start], newCount))
+		for _, op := range ops[start : end+1] {
+			fmt.Fprintf(w, "%c%s\n", op.kind, op.text)
+		}
+
+		i = end + 1
+	}
+}
+
+// hunkRange formats the line range of a hunk header.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// splitLines splits text into lines without the trailing newline.
+func splitLines(text string) []string {
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines computes the shortest edit script between a and b using the Myers algorithm.
+func diffLines(a, b []string) []lineOp {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+
+	// trace[d] holds the furthest x per diagonal before step d, for k in [-d-1, d+1]
+	var trace [][]int
+	found := -1
+	for d := 0; d <= n+m && found < 0; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				found = d
+				break
+			}
+		}
+	}
+
+	// Walk the trace backwards to recover the edit script
+	var ops []lineOp
+	x, y := n, m
+	for d := found; d > 0; d-- {
+		snapshot := trace[d]
+		at := func(k int) int { return snapshot[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, lineOp{' ', a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			ops = append(ops, lineOp{'+', b[y-1]})
+		} else {
+			ops = append(ops, lineOp{'-', a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		ops = append(ops, lineOp{' ', a[x-1]})
+		x--
+		y--
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
@@ -0,0 +1,91 @@
+package policy
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to 20 with the given lines replaced.
+func numbered(replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= 20; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = strconv.Itoa(i)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	header := "--- old\n+++ new\n"
+	tests := map[string]struct {
+		from, to string
+		want     string
+	}{
+		"both empty": {
+			from: "",
+			to:   "",
+			want: "",
+		},
+		"equal": {
+			from: "a\nb\n",
+			to:   "a\nb\n",
+			want: "",
+		},
+		"from empty": {
+			from: "",
+			to:   "a\nb\n",
+			want: header + "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		"to empty": {
+			from: "a\nb\n",
+			to:   "",
+			want: header + "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		"insertion only": {
+			from: "a\nc\n",
+			to:   "a\nb\nc\n",
+			want: header + "@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		"deletion only": {
+			from: "a\nb\nc\n",
+			to:   "a\nc\n",
+			want: header + "@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		"close changes merged": {
+			from: numbered(nil),
+			to:   numbered(map[int]string{5: "five", 11: "eleven"}),
+			want: header + "@@ -2,13 +2,13 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n-11\n+eleven\n 12\n 13\n 14\n",
+		},
+		"merged at twice the context": {
+			from: numbered(nil),
+			to:   numbered(map[int]string{5: "five", 12: "twelve"}),
+			want: header + "@@ -2,14 +2,14 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n 9\n 10\n 11\n-12\n+twelve\n 13\n 14\n 15\n",
+		},
+		"distant changes split": {
+			from: numbered(nil),
+			to:   numbered(map[int]string{5: "five", 13: "thirteen"}),
+			want: header + "@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n" +
+				"@@ -10,7 +10,7 @@\n 10\n 11\n 12\n-13\n+thirteen\n 14\n 15\n 16\n",
+		},
+		"context clipped at the start": {
+			from: numbered(nil),
+			to:   numbered(map[int]string{2: "two", 15: "fifteen"}),
+			want: header + "@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -12,7 +12,7 @@\n 12\n 13\n 14\n-15\n+fifteen\n 16\n 17\n 18\n",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var b strings.Builder
+			WriteUnifiedDiff(&b, "old", "new", []byte(tt.from), []byte(tt.to))
+			if got := b.String(); got != tt.want {
+				t.Errorf("WriteUnifiedDiff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidPolicy is returned when the generated policy fails validation.
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+var (
+	groupNameRegex = regexp.MustCompile(`^group:[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+	// A member is either a bare username ("john@") or an email address ("john@example.com").
+	memberRegex = regexp.MustCompile(`^[^@\s]+@([^@\s]+\.[^@\s.]+)?$`)
+)
+
+// aclRule is the part of an ACL rule relevant for validation.
+type aclRule struct {
+	Action string   `json:"action"`
+	Src    []string `json:"src"`
+	Dst    []string `json:"dst"`
+}
+
+// ValidationError lists every problem found in a policy.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error returns all problems in a single line.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidPolicy, strings.Join(e.Problems, "; "))
+}
+
+// Unwrap makes the error match ErrInvalidPolicy.
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidPolicy
+}
+
+// Validate checks the groups and ACL rules of the document. An empty group is valid,
+// it grants access to nobody.
+func Validate(doc *Document) error {
+	groups, err := doc.Groups()
+	if err != nil {
+		return err
+	}
+
+	var rules []aclRule
+	if _, err := doc.Decode(SectionACLs, &rules); err != nil {
+		return err
+	}
+
+	var problems []string
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !groupNameRegex.MatchString(key) {
+			problems = append(problems, fmt.Sprintf("group %q has an invalid name", key))
+		}
+		for _, member := range groups[key] {
+			if !memberRegex.MatchString(member) {
+				problems = append(problems, fmt.Sprintf("group %q has a malformed member %q", key, member))
+			}
+		}
+	}
+
+	for i, rule := range rules {
+		for _, src := range rule.Src {
+			if name, ok := groupRef(src); ok && !hasGroup(groups, name) {
+				problems = append(problems, fmt.Sprintf("acls[%d] src references unknown group %q", i, name))
+			}
+		}
+		for _, dst := range rule.Dst {
+			if name, ok := groupRef(dst); ok && !hasGroup(groups, name) {
+				problems = append(problems, fmt.Sprintf("acls[%d] dst references unknown group %q", i, name))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// GroupReferences returns the group keys referenced anywhere in the document outside the groups section,
+// e.g. in ACL rules, tagOwners, autoApprovers or ssh rules.
+func (d *Document) GroupReferences() (map[string]bool, error) {
+	refs := make(map[string]bool)
+	for _, name := range d.Sections() {
+		if name == SectionGroups {
+			continue
+		}
+		var value any
+		if _, err := d.Decode(name, &value); err != nil {
+			return nil, err
+		}
+		collectGroupRefs(value, refs)
+	}
+	return refs, nil
+}
+
+// collectGroupRefs adds the group keys referenced by the string values in value to refs.
+func collectGroupRefs(value any, refs map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		if name, ok := groupRef(v); ok {
+			refs[name] = true
+		}
+	case []any:
+		for _, item := range v {
+			collectGroupRefs(item, refs)
+		}
+	case map[string]any:
+		for _, item := range v {
+			collectGroupRefs(item, refs)
+		}
+	}
+}
+
+// hasGroup reports whether the group exists, even if it has no members.
+func hasGroup(groups map[string][]string, name string) bool {
+	_, ok := groups[name]
+	return ok
+}
+
+// groupRef returns the group key referenced by an ACL src or dst entry such as
+// "group:admins" or "group:admins:22".
+func groupRef(entry string) (string, bool) {
+	if !strings.HasPrefix(entry, GroupKeyPrefix) {
+		return "", false
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(entry, GroupKeyPrefix), ":")
+	return GroupKeyPrefix + name, true
+}
@@ -0,0 +1,80 @@
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		policy   string
+		problems []string
+	}{
+		"valid": {
+			policy: `{
+				"groups": {"group:admins": ["alice@example.com", "bob@"], "group:empty": []},
+				"acls": [{"action": "accept", "src": ["group:admins", "group:empty"], "dst": ["group:admins:22", "*:*"]}]
+			}`,
+		},
+		"no sections": {policy: `{}`},
+		"invalid group name": {
+			policy:   `{"groups": {"group:-bad": [], "admins": []}}`,
+			problems: []string{`group "admins" has an invalid name`, `group "group:-bad" has an invalid name`},
+		},
+		"malformed member": {
+			policy:   `{"groups": {"group:a": ["alice", "bob@localhost.", "carol @example.com"]}}`,
+			problems: []string{`group "group:a" has a malformed member "alice"`, `group "group:a" has a malformed member "bob@localhost."`, `group "group:a" has a malformed member "carol @example.com"`},
+		},
+		"unknown group": {
+			policy:   `{"groups": {}, "acls": [{"action": "accept", "src": ["group:gone"], "dst": ["group:other:*"]}]}`,
+			problems: []string{`acls[0] src references unknown group "group:gone"`, `acls[0] dst references unknown group "group:other"`},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.policy))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			err = Validate(doc)
+			if len(tt.problems) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || !errors.Is(err, ErrInvalidPolicy) {
+				t.Fatalf("Validate() = %v, want ValidationError", err)
+			}
+			if !reflect.DeepEqual(validationErr.Problems, tt.problems) {
+				t.Errorf("problems = %q\nwant       %q", validationErr.Problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestGroupReferences(t *testing.T) {
+	doc, err := Parse([]byte(`{
+		"groups": {"group:listed": [], "group:unused": []},
+		"tagOwners": {"tag:server": ["group:owners"]},
+		"acls": [{"action": "accept", "src": ["group:dev", "autogroup:member"], "dst": ["group:ops:443"]}],
+		"ssh": [{"action": "accept", "src": ["group:ssh"], "dst": ["autogroup:self"], "users": ["root"]}]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	refs, err := doc.GroupReferences()
+	if err != nil {
+		t.Fatalf("GroupReferences: %v", err)
+	}
+	want := map[string]bool{"group:owners": true, "group:dev": true, "group:ops": true, "group:ssh": true}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("GroupReferences() = %v, want %v", refs, want)
+	}
+}
@@ -0,0 +1,148 @@
+package reload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// CommandReloader implements Reloader by running an arbitrary command.
+type CommandReloader struct {
+	args []string
+	log  logger.ILogger
+}
+
+var _ Reloader = (*CommandReloader)(nil)
+
+// commandData is available in the command arguments as template fields, e.g. {{.PolicyPath}}.
+type commandData struct {
+	PolicyPath string
+	Container  string
+	PIDFile    string
+	Unit       string
+}
+
+// NewCommandReloader creates a reloader running the configured command.
+// The whole command is rendered as a text/template and then split into arguments like a shell
+// would, without running one: see splitCommand for the quoting rules.
+func NewCommandReloader(cfg config.ReloadConfig, policyPath string, log logger.ILogger) (*CommandReloader, error) {
+	if strings.TrimSpace(cfg.Command) == "" {
+		return nil, errors.New("reload command is not configured (RELOAD_COMMAND)")
+	}
+
+	tmpl, err := template.New("command").Option("missingkey=error").Parse(cfg.Command)
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload command: %w", err)
+	}
+	var buf bytes.Buffer
+	data := commandData{
+		PolicyPath: policyPath,
+		Container:  cfg.Container,
+		PIDFile:    cfg.PIDFile,
+		Unit:       cfg.Unit,
+	}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render reload command: %w", err)
+	}
+
+	args, err := splitCommand(buf.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid reload command: %w", err)
+	}
+	if len(args) == 0 {
+		return nil, errors.New("reload command is empty after rendering (RELOAD_COMMAND)")
+	}
+
+	return &CommandReloader{
+		args: args,
+		log:  log,
+	}, nil
+}
+
+// Name returns the strategy identifier including the program name.
+func (r *CommandReloader) Name() string {
+	return StrategyCommand + ":" + r.args[0]
+}
+
+// Reload runs the command, reporting its output on failure.
+func (r *CommandReloader) Reload(ctx context.Context) error {
+	r.log.Debug("Running reload command", "command", r.args)
+	output, err := exec.CommandContext(ctx, r.args[0], r.args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reload command failed: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	r.log.Info("Reload command finished", "command", r.args[0])
+	return nil
+}
+
+// splitCommand splits a command line into arguments following the POSIX shell quoting rules:
+// arguments are separated by unquoted whitespace, single quotes keep everything literally,
+// double quotes keep everything except `\"` and `\\`, and a backslash outside of quotes escapes
+// the next character. Variables, globs and other shell features are not interpreted.
+func splitCommand(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, r := range command {
+		switch {
+		case escaped:
+			if quote == '"' && r != '"' && r != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(r)
+			escaped = false
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\\':
+			escaped = true
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if escaped {
+		return nil, errors.New("command ends with an unfinished escape")
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command", quote)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,73 @@
+package reload
+
+import (
+	"io"
+	"slices"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := map[string]struct {
+		command string
+		want    []string
+	}{
+		"whitespace":      {"  kill  -HUP\t1 ", []string{"kill", "-HUP", "1"}},
+		"single quotes":   {`sh -c 'kill -HUP $(cat /run/hs.pid)'`, []string{"sh", "-c", "kill -HUP $(cat /run/hs.pid)"}},
+		"double quotes":   {`echo "a \"b\" \\ \n"`, []string{"echo", `a "b" \ \n`}},
+		"escaped space":   {`cp my\ file dst`, []string{"cp", "my file", "dst"}},
+		"empty argument":  {`cmd '' ""`, []string{"cmd", "", ""}},
+		"adjacent quotes": {`a'b c'"d e"f`, []string{"ab cd ef"}},
+		"empty":           {"   ", nil},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := splitCommand(tt.command)
+			if err != nil {
+				t.Fatalf("splitCommand(%q): %v", tt.command, err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("splitCommand(%q) = %q, want %q", tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCommandErrors(t *testing.T) {
+	for _, command := range []string{`echo 'open`, `echo "open`, `echo trailing\`} {
+		if _, err := splitCommand(command); err == nil {
+			t.Errorf("splitCommand(%q) succeeded, want error", command)
+		}
+	}
+}
+
+func TestNewCommandReloaderRendersTemplate(t *testing.T) {
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+
+	cfg := config.ReloadConfig{
+		Command:   `/usr/local/bin/reload --policy '{{ .PolicyPath }}' --container {{ .Container }}`,
+		Container: "headscale",
+	}
+	r, err := NewCommandReloader(cfg, "/etc/head scale/acl.json", log)
+	if err != nil {
+		t.Fatalf("NewCommandReloader: %v", err)
+	}
+
+	want := []string{"/usr/local/bin/reload", "--policy", "/etc/head scale/acl.json", "--container", "headscale"}
+	if !slices.Equal(r.args, want) {
+		t.Errorf("args = %q, want %q", r.args, want)
+	}
+	if r.Name() != StrategyCommand+":/usr/local/bin/reload" {
+		t.Errorf("Name() = %q", r.Name())
+	}
+
+	if _, err := NewCommandReloader(config.ReloadConfig{Command: "reload {{ .Missing }}"}, "acl.json", log); err == nil {
+		t.Error("NewCommandReloader accepted an unknown template field")
+	}
+}
@@ -0,0 +1,64 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/docker"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// DockerReloader implements Reloader by sending SIGHUP to the Headscale container.
+type DockerReloader struct {
+	client    docker.DockerClient
+	container string
+	log       logger.ILogger
+}
+
+var _ Reloader = (*DockerReloader)(nil)
+
+// NewDockerReloader creates a reloader for the named container.
+func NewDockerReloader(client docker.DockerClient, container string, log logger.ILogger) *DockerReloader {
+	return &DockerReloader{
+		client:    client,
+		container: container,
+		log:       log,
+	}
+}
+
+// Name returns the strategy identifier including the container name.
+func (r *DockerReloader) Name() string {
+	return StrategyDocker + ":" + r.container
+}
+
+// Reload checks that the container is running and sends it SIGHUP.
+func (r *DockerReloader) Reload(ctx context.Context) error {
+	r.log.Debug("Reloading headscale container", "container", r.container)
+	state, err := r.client.InspectContainer(ctx, r.container)
+	if err != nil {
+		return err
+	}
+	if !state.Running {
+		return fmt.Errorf("headscale container %s is not running (status: %s)", r.container, state.Status)
+	}
+
+	if err := r.client.SignalContainer(ctx, r.container, "HUP"); err != nil {
+		return err
+	}
+
+	r.log.Info("Headscale container reloaded", "container", r.container)
+	return nil
+}
+
+// Verify checks that the container is still running and not restarting.
+func (r *DockerReloader) Verify(ctx context.Context) error {
+	state, err := r.client.InspectContainer(ctx, r.container)
+	if err != nil {
+		return err
+	}
+	if !state.Running || state.Restarting {
+		return fmt.Errorf("headscale container %s is not running after reload (status: %s, exit code: %d, error: %s)",
+			r.container, state.Status, state.ExitCode, state.Error)
+	}
+	return nil
+}
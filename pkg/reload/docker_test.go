@@ -0,0 +1,108 @@
+package reload
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/docker"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// fakeEngine serves a minimal Docker Engine API on a unix socket and records kill signals.
+type fakeEngine struct {
+	running bool
+	signals []string
+}
+
+func (e *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/containers/headscale/json":
+		if e.running {
+			io.WriteString(w, `{"State":{"Status":"running","Running":true}}`)
+		} else {
+			io.WriteString(w, `{"State":{"Status":"exited","Running":false,"ExitCode":1}}`)
+		}
+	case r.Method == http.MethodPost && r.URL.Path == "/containers/headscale/kill":
+		e.signals = append(e.signals, r.URL.Query().Get("signal"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"No such container: `+strings.Split(strings.TrimPrefix(r.URL.Path, "/containers/"), "/")[0]+`"}`)
+	}
+}
+
+func newDockerReloader(t *testing.T, engine *fakeEngine, container string) *DockerReloader {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "docker")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	srv := &http.Server{Handler: engine}
+	go srv.Serve(listener)
+	t.Cleanup(func() { srv.Close() })
+
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	client, err := docker.NewClient("unix://"+socket, log)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return NewDockerReloader(client, container, log)
+}
+
+func TestDockerReloaderSendsHUP(t *testing.T) {
+	engine := &fakeEngine{running: true}
+	r := newDockerReloader(t, engine, "headscale")
+
+	if err := r.Reload(context.Background()); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if len(engine.signals) != 1 || engine.signals[0] != "HUP" {
+		t.Errorf("signals = %q, want [HUP]", engine.signals)
+	}
+	if err := r.Verify(context.Background()); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestDockerReloaderContainerNotRunning(t *testing.T) {
+	engine := &fakeEngine{running: false}
+	r := newDockerReloader(t, engine, "headscale")
+
+	err := r.Reload(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "is not running (status: exited)") {
+		t.Fatalf("Reload error = %v, want not running", err)
+	}
+	if len(engine.signals) != 0 {
+		t.Errorf("signals = %q, want none", engine.signals)
+	}
+	if err := r.Verify(context.Background()); err == nil {
+		t.Error("Verify succeeded for a stopped container")
+	}
+}
+
+func TestDockerReloaderReportsAPIError(t *testing.T) {
+	r := newDockerReloader(t, &fakeEngine{}, "missing")
+
+	err := r.Reload(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "No such container: missing") {
+		t.Fatalf("Reload error = %v, want API message", err)
+	}
+}
@@ -0,0 +1,80 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// PIDFileReloader implements Reloader by sending SIGHUP to the process in a pidfile.
+type PIDFileReloader struct {
+	path string
+	log  logger.ILogger
+}
+
+var _ Reloader = (*PIDFileReloader)(nil)
+
+// NewPIDFileReloader creates a reloader for the process whose PID is stored at path.
+func NewPIDFileReloader(path string, log logger.ILogger) *PIDFileReloader {
+	return &PIDFileReloader{
+		path: path,
+		log:  log,
+	}
+}
+
+// Name returns the strategy identifier including the pidfile path.
+func (r *PIDFileReloader) Name() string {
+	return StrategyPIDFile + ":" + r.path
+}
+
+// Reload reads the PID and sends SIGHUP to the process.
+func (r *PIDFileReloader) Reload(context.Context) error {
+	pid, process, err := r.process()
+	if err != nil {
+		return err
+	}
+
+	r.log.Debug("Sending SIGHUP to headscale process", "pid", pid, "pidfile", r.path)
+	if err := process.Signal(syscall.SIGHUP); err != nil {
+		return fmt.Errorf("failed to signal headscale process %d: %w", pid, err)
+	}
+
+	r.log.Info("Headscale process reloaded", "pid", pid)
+	return nil
+}
+
+// Verify checks that the process in the pidfile is still alive.
+func (r *PIDFileReloader) Verify(context.Context) error {
+	pid, process, err := r.process()
+	if err != nil {
+		return err
+	}
+	if err := process.Signal(syscall.Signal(0)); err != nil {
+		return fmt.Errorf("headscale process %d is not running after reload: %w", pid, err)
+	}
+	return nil
+}
+
+// process reads the PID from the pidfile and finds the process.
+func (r *PIDFileReloader) process() (int, *os.Process, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, nil, fmt.Errorf("invalid PID in %s: %q", r.path, strings.TrimSpace(string(data)))
+	}
+
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, nil, err
+	}
+	return pid, process, nil
+}
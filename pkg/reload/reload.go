@@ -0,0 +1,62 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/docker"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// Reload strategies
+const (
+	StrategyDocker  = "docker"
+	StrategyPIDFile = "pidfile"
+	StrategySystemd = "systemd"
+	StrategyCommand = "command"
+	StrategyNone    = "none"
+)
+
+// Reloader makes Headscale load a changed policy file.
+type Reloader interface {
+	// Name returns a short identifier of the strategy used in logs.
+	Name() string
+	// Reload tells Headscale to reload its policy.
+	Reload(ctx context.Context) error
+}
+
+// NewReloader creates the reloader selected by the configured strategy.
+func NewReloader(cfg config.ReloadConfig, policyPath string, log logger.ILogger) (Reloader, error) {
+	switch cfg.Strategy {
+	case StrategyDocker:
+		client, err := docker.NewClient(cfg.DockerHost, log)
+		if err != nil {
+			return nil, err
+		}
+		return NewDockerReloader(client, cfg.Container, log), nil
+	case StrategyPIDFile:
+		return NewPIDFileReloader(cfg.PIDFile, log), nil
+	case StrategySystemd:
+		return NewSystemdReloader(cfg.Unit, log), nil
+	case StrategyCommand:
+		return NewCommandReloader(cfg, policyPath, log)
+	case StrategyNone, "":
+		return NoopReloader{}, nil
+	default:
+		return nil, fmt.Errorf("unknown reload strategy: %s", cfg.Strategy)
+	}
+}
+
+// NoopReloader implements Reloader without reloading anything.
+type NoopReloader struct{}
+
+// Name returns the strategy identifier.
+func (NoopReloader) Name() string {
+	return StrategyNone
+}
+
+// Reload does nothing.
+func (NoopReloader) Reload(context.Context) error {
+	return nil
+}
@@ -0,0 +1,85 @@
+package reload
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/coreos/go-systemd/v22/dbus"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// systemdTimeout limits how long to wait for the reload job to finish
+const systemdTimeout = 30 * time.Second
+
+// SystemdReloader implements Reloader by reloading a systemd unit through D-Bus.
+type SystemdReloader struct {
+	unit string
+	log  logger.ILogger
+}
+
+var _ Reloader = (*SystemdReloader)(nil)
+
+// NewSystemdReloader creates a reloader for the given unit.
+func NewSystemdReloader(unit string, log logger.ILogger) *SystemdReloader {
+	return &SystemdReloader{
+		unit: unit,
+		log:  log,
+	}
+}
+
+// Name returns the strategy identifier including the unit name.
+func (r *SystemdReloader) Name() string {
+	return StrategySystemd + ":" + r.unit
+}
+
+// Reload queues a reload job for the unit, the same as `systemctl reload`, and waits for it.
+func (r *SystemdReloader) Reload(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, systemdTimeout)
+	defer cancel()
+
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	r.log.Debug("Reloading headscale unit", "unit", r.unit)
+	done := make(chan string, 1)
+	if _, err := conn.ReloadUnitContext(ctx, r.unit, "replace", done); err != nil {
+		return fmt.Errorf("failed to reload unit %s: %w", r.unit, err)
+	}
+
+	select {
+	case result := <-done:
+		if result != "done" {
+			return fmt.Errorf("reload of unit %s finished with result %q", r.unit, result)
+		}
+	case <-ctx.Done():
+		return fmt.Errorf("reload of unit %s timed out: %w", r.unit, ctx.Err())
+	}
+
+	r.log.Info("Headscale unit reloaded", "unit", r.unit)
+	return nil
+}
+
+// Verify checks that the unit is still active.
+func (r *SystemdReloader) Verify(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, systemdTimeout)
+	defer cancel()
+
+	conn, err := dbus.NewSystemConnectionContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to systemd: %w", err)
+	}
+	defer conn.Close()
+
+	prop, err := conn.GetUnitPropertyContext(ctx, r.unit, "ActiveState")
+	if err != nil {
+		return fmt.Errorf("failed to read state of unit %s: %w", r.unit, err)
+	}
+	if state, _ := prop.Value.Value().(string); state != "active" {
+		return fmt.Errorf("headscale unit %s is %s after reload", r.unit, prop.Value.String())
+	}
+	return nil
+}
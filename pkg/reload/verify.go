@@ -0,0 +1,114 @@
+package reload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// healthPath is the Headscale health endpoint
+const healthPath = "/health"
+
+// healthTimeout limits a single health request
+const healthTimeout = 5 * time.Second
+
+// Verifier confirms that Headscale is still healthy after a reload.
+type Verifier interface {
+	Verify(ctx context.Context) error
+}
+
+// ChainVerifier waits for Headscale to process the reload and then runs every check.
+type ChainVerifier struct {
+	delay  time.Duration
+	checks []Verifier
+	log    logger.ILogger
+}
+
+var _ Verifier = (*ChainVerifier)(nil)
+
+// NewVerifier creates the post-reload checks for the reloader and, if a health URL is
+// configured, the Headscale health endpoint. It returns nil when verification is disabled.
+// The checks only notice a Headscale that stopped or became unhealthy. A policy rejected on
+// SIGHUP is not detected: Headscale logs it, keeps the old policy and stays healthy.
+func NewVerifier(cfg config.ReloadConfig, reloader Reloader, log logger.ILogger) Verifier {
+	if !cfg.IsVerify {
+		return nil
+	}
+
+	var checks []Verifier
+	if v, ok := reloader.(Verifier); ok {
+		checks = append(checks, v)
+	}
+	if cfg.HealthURL != "" {
+		checks = append(checks, NewHealthVerifier(cfg.HealthURL))
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+
+	return &ChainVerifier{
+		delay:  cfg.VerifyDelay,
+		checks: checks,
+		log:    log,
+	}
+}
+
+// Verify runs the checks after the configured delay and joins their errors.
+func (v *ChainVerifier) Verify(ctx context.Context) error {
+	v.log.Debug("Waiting before verifying headscale reload", "delay", v.delay)
+	timer := time.NewTimer(v.delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return fmt.Errorf("headscale reload verification cancelled: %w", ctx.Err())
+	}
+
+	var errs []error
+	for _, check := range v.checks {
+		if err := check.Verify(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HealthVerifier checks the Headscale health endpoint.
+type HealthVerifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+var _ Verifier = (*HealthVerifier)(nil)
+
+// NewHealthVerifier creates a check against the health endpoint of the Headscale server at baseURL.
+func NewHealthVerifier(baseURL string) *HealthVerifier {
+	return &HealthVerifier{
+		url:        strings.TrimRight(baseURL, "/") + healthPath,
+		httpClient: &http.Client{Timeout: healthTimeout},
+	}
+}
+
+// Verify fails unless the health endpoint answers with 200 OK.
+func (v *HealthVerifier) Verify(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("headscale health check failed: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("headscale health check returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,256 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/syncer"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/webhook"
+)
+
+// readHeaderTimeout protects the server against slow clients
+const readHeaderTimeout = 10 * time.Second
+
+// maxWebhookBody limits the size of accepted webhook payloads
+const maxWebhookBody = 1 << 20
+
+// queueRetryDelay is how often a queued sync checks whether the running one has finished
+const queueRetryDelay = time.Second
+
+// Server exposes health, readiness, status and metrics endpoints of the sync.
+type Server struct {
+	syncer      *syncer.Syncer
+	source      source.IdentitySource
+	readyWindow time.Duration
+	syncToken   string
+	hookSecret  []byte
+	debouncer   *webhook.Debouncer
+	queue       *webhook.Debouncer
+	httpServer  *http.Server
+	log         logger.ILogger
+}
+
+// NewServer creates an HTTP server listening on the configured port. The sync is ready while its
+// last successful run is not older than readyWindow and the source is reachable.
+// POST /sync and POST /webhook are only served when their token or secret is set.
+// A POST /sync received while a sync is running queues a single follow-up run.
+func NewServer(cfg config.AppConfig, s *syncer.Syncer, identitySource source.IdentitySource, readyWindow time.Duration, log logger.ILogger) *Server {
+	srv := &Server{
+		syncer:      s,
+		source:      identitySource,
+		readyWindow: readyWindow,
+		syncToken:   cfg.SyncToken,
+		hookSecret:  []byte(cfg.WebhookSecret),
+		log:         log,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", srv.handleHealthz)
+	mux.HandleFunc("GET /readyz", srv.handleReadyz)
+	mux.HandleFunc("GET /status", srv.handleStatus)
+	mux.Handle("GET /metrics", promhttp.Handler())
+	if srv.syncToken != "" {
+		srv.queue = webhook.NewDebouncer(queueRetryDelay, func() { srv.queuedSync("http", srv.queue) })
+		mux.Handle("POST /sync", srv.requireToken(http.HandlerFunc(srv.handleSync)))
+	}
+	if len(srv.hookSecret) > 0 {
+		srv.debouncer = webhook.NewDebouncer(cfg.WebhookDebounce, func() { srv.queuedSync("webhook", srv.debouncer) })
+		mux.HandleFunc("POST /webhook", srv.handleWebhook)
+	}
+
+	srv.httpServer = &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	return srv
+}
+
+// Start listens in the background. A failure to listen is logged.
+func (srv *Server) Start() {
+	go func() {
+		srv.log.Info("HTTP server listening", "addr", srv.httpServer.Addr)
+		if err := srv.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			srv.log.Error("HTTP server failed", "error", err)
+		}
+	}()
+}
+
+// Shutdown stops accepting requests, drops pending webhook and queued syncs and waits for active requests until ctx is done.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	for _, d := range []*webhook.Debouncer{srv.debouncer, srv.queue} {
+		if d != nil {
+			d.Stop()
+		}
+	}
+	return srv.httpServer.Shutdown(ctx)
+}
+
+// handleHealthz reports that the process is alive.
+func (srv *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handleReadyz reports whether the last sync succeeded recently and the source is reachable.
+func (srv *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	status := srv.syncer.Status()
+
+	if status.LastSuccess.IsZero() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "no successful sync yet"})
+		return
+	}
+	if age := time.Since(status.LastSuccess); age > srv.readyWindow {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"status": fmt.Sprintf("last successful sync %s ago", age.Round(time.Second)),
+		})
+		return
+	}
+
+	if pinger, ok := srv.source.(source.Pinger); ok {
+		if err := pinger.Ping(r.Context()); err != nil {
+			srv.log.Warn("Readiness check failed, source unreachable", "source", srv.source.Name(), "error", err)
+			writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+				"status": fmt.Sprintf("%s unreachable: %v", srv.source.Name(), err),
+			})
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// handleStatus returns the status of the last sync run.
+func (srv *Server) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, srv.syncer.Status())
+}
+
+// syncResponse is the body returned by POST /sync.
+type syncResponse struct {
+	Outcome string         `json:"outcome"`
+	Error   string         `json:"error,omitempty"`
+	Result  *syncer.Result `json:"result,omitempty"`
+}
+
+// handleSync runs a sync immediately and returns its outcome. While another sync is running, a
+// single follow-up run is queued and 202 is returned. The sync is not cancelled when the client disconnects.
+func (srv *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	srv.log.Info("Sync requested over HTTP")
+	result, err := srv.syncer.Run(context.WithoutCancel(r.Context()))
+	if errors.Is(err, syncer.ErrSyncInProgress) {
+		srv.log.Info("Sync queued until the running sync has finished")
+		srv.queue.Trigger()
+		writeJSON(w, http.StatusAccepted, syncResponse{
+			Outcome: metrics.OutcomeQueued,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, syncer.ErrShuttingDown) {
+		writeJSON(w, http.StatusServiceUnavailable, syncResponse{
+			Outcome: metrics.OutcomeSkipped,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		srv.log.Error("Sync failed", "error", err)
+		writeJSON(w, http.StatusInternalServerError, syncResponse{
+			Outcome: metrics.Outcome(err),
+			Error:   err.Error(),
+			Result:  result,
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, syncResponse{
+		Outcome: metrics.OutcomeSuccess,
+		Result:  result,
+	})
+}
+
+// handleWebhook verifies a change event of the identity provider and schedules a debounced sync.
+func (srv *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBody))
+	if err != nil {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"status": "payload too large"})
+		return
+	}
+
+	if err := webhook.VerifySignature(srv.hookSecret, r.Header, body); err != nil {
+		srv.log.Warn("Webhook rejected", "remote", r.RemoteAddr, "error", err)
+		metrics.WebhookEvents.WithLabelValues("unknown", metrics.OutcomeRejected).Inc()
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "invalid signature"})
+		return
+	}
+
+	event, err := webhook.ParseEvent(body)
+	if err != nil {
+		srv.log.Warn("Webhook rejected", "remote", r.RemoteAddr, "error", err)
+		metrics.WebhookEvents.WithLabelValues("unknown", metrics.OutcomeRejected).Inc()
+		writeJSON(w, http.StatusBadRequest, map[string]string{"status": "invalid payload"})
+		return
+	}
+
+	if !event.Relevant {
+		srv.log.Debug("Webhook event ignored", "format", event.Format, "type", event.Type)
+		metrics.WebhookEvents.WithLabelValues(event.Format, metrics.OutcomeIgnored).Inc()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "ignored"})
+		return
+	}
+
+	srv.log.Info("Webhook event received, sync scheduled", "format", event.Format, "type", event.Type)
+	metrics.WebhookEvents.WithLabelValues(event.Format, metrics.OutcomeAccepted).Inc()
+	srv.debouncer.Trigger()
+	writeJSON(w, http.StatusAccepted, map[string]string{"status": "sync scheduled"})
+}
+
+// queuedSync runs a sync scheduled by d, i.e. once a burst of webhook events has settled or a queued
+// POST /sync is due. If another sync is running, it is retried after the delay of d so no request is lost.
+func (srv *Server) queuedSync(trigger string, d *webhook.Debouncer) {
+	srv.log.Info("Running queued sync...", "trigger", trigger)
+	result, err := srv.syncer.Run(context.Background())
+	if errors.Is(err, syncer.ErrSyncInProgress) {
+		d.Trigger()
+		return
+	}
+	if errors.Is(err, syncer.ErrShuttingDown) {
+		return
+	}
+	if err != nil {
+		srv.log.Error("Sync failed", "error", err)
+		return
+	}
+	srv.log.Debug("Sync finished", "changed", result.Changed, "changed_groups", len(result.Diff))
+}
+
+// requireToken rejects requests without the configured bearer token.
+func (srv *Server) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(srv.syncToken)) != 1 {
+			srv.log.Warn("Unauthorized request", "path", r.URL.Path, "remote", r.RemoteAddr)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"status": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeJSON writes v as a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
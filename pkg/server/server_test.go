@@ -0,0 +1,337 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/syncer"
+)
+
+// fakeSource returns its identities or fetchErr. While block is open, each fetch waits for it to be closed.
+type fakeSource struct {
+	identities []source.Identity
+	fetchErr   error
+	pingErr    error
+	block      chan struct{}
+	started    chan struct{}
+	calls      atomic.Int32
+}
+
+func (s *fakeSource) Name() string { return "fake" }
+
+func (s *fakeSource) FetchIdentities(ctx context.Context) ([]source.Identity, error) {
+	s.calls.Add(1)
+	if s.started != nil {
+		s.started <- struct{}{}
+	}
+	if s.block != nil {
+		<-s.block
+	}
+	return s.identities, s.fetchErr
+}
+
+func (s *fakeSource) Ping(context.Context) error { return s.pingErr }
+
+// fakeSink keeps the policy in memory.
+type fakeSink struct {
+	policy []byte
+}
+
+func (s *fakeSink) Name() string                                 { return "fake" }
+func (s *fakeSink) Read(context.Context) ([]byte, error)         { return s.policy, nil }
+func (s *fakeSink) Write(_ context.Context, policy []byte) error { s.policy = policy; return nil }
+func (s *fakeSink) Notify(context.Context) error                 { return nil }
+
+func newTestServer(t *testing.T, cfg config.AppConfig, src source.IdentitySource) (*Server, *syncer.Syncer) {
+	t.Helper()
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	s := syncer.NewSyncer(&config.Config{App: cfg}, src, &fakeSink{}, log)
+	srv := NewServer(cfg, s, src, time.Minute, log)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+	return srv, s
+}
+
+func serve(srv *Server, method, path, auth string) (*httptest.ResponseRecorder, map[string]any) {
+	req := httptest.NewRequest(method, path, nil)
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	rec := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(rec, req)
+
+	var body map[string]any
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	return rec, body
+}
+
+func TestSyncRequiresToken(t *testing.T) {
+	tests := map[string]struct {
+		auth string
+		want int
+	}{
+		"missing":      {"", http.StatusUnauthorized},
+		"wrong token":  {"Bearer wrong", http.StatusUnauthorized},
+		"wrong scheme": {"Basic secret", http.StatusUnauthorized},
+		"prefix only":  {"Bearer secre", http.StatusUnauthorized},
+		"valid":        {"Bearer secret", http.StatusOK},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := &fakeSource{}
+			srv, _ := newTestServer(t, config.AppConfig{SyncToken: "secret"}, src)
+
+			rec, body := serve(srv, http.MethodPost, "/sync", tt.auth)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			if ran := src.calls.Load() > 0; ran != (tt.want == http.StatusOK) {
+				t.Errorf("sync ran = %v for status %d", ran, rec.Code)
+			}
+			if tt.want == http.StatusOK && body["outcome"] != metrics.OutcomeSuccess {
+				t.Errorf("outcome = %v, want %s", body["outcome"], metrics.OutcomeSuccess)
+			}
+		})
+	}
+}
+
+func TestSyncDisabledWithoutToken(t *testing.T) {
+	src := &fakeSource{}
+	srv, _ := newTestServer(t, config.AppConfig{}, src)
+
+	if rec, _ := serve(srv, http.MethodPost, "/sync", "Bearer "); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if src.calls.Load() != 0 {
+		t.Error("sync ran without a configured token")
+	}
+}
+
+func TestSyncQueuedWhileRunning(t *testing.T) {
+	src := &fakeSource{block: make(chan struct{}), started: make(chan struct{}, 4)}
+	srv, s := newTestServer(t, config.AppConfig{SyncToken: "secret"}, src)
+
+	done := make(chan error)
+	go func() {
+		_, err := s.Run(context.Background())
+		done <- err
+	}()
+	<-src.started
+
+	// Requests during the run share a single follow-up run
+	for range 2 {
+		rec, body := serve(srv, http.MethodPost, "/sync", "Bearer secret")
+		if rec.Code != http.StatusAccepted || body["outcome"] != metrics.OutcomeQueued {
+			t.Fatalf("status = %d, body = %v, want 202 queued", rec.Code, body)
+		}
+	}
+
+	close(src.block)
+	if err := <-done; err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	select {
+	case <-src.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued sync did not run")
+	}
+
+	time.Sleep(2 * queueRetryDelay)
+	if calls := src.calls.Load(); calls != 2 {
+		t.Errorf("sync ran %d times, want 2", calls)
+	}
+}
+
+func TestSyncShuttingDown(t *testing.T) {
+	src := &fakeSource{}
+	srv, s := newTestServer(t, config.AppConfig{SyncToken: "secret"}, src)
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	rec, body := serve(srv, http.MethodPost, "/sync", "Bearer secret")
+	if rec.Code != http.StatusServiceUnavailable || body["outcome"] != metrics.OutcomeSkipped {
+		t.Errorf("status = %d, body = %v, want 503 skipped", rec.Code, body)
+	}
+	if src.calls.Load() != 0 {
+		t.Error("sync ran after shutdown")
+	}
+}
+
+// postWebhook sends body to POST /webhook, signed with secret unless it is empty.
+func postWebhook(srv *Server, body []byte, secret string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewReader(body))
+	if secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	rec := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestWebhook(t *testing.T) {
+	tests := map[string]struct {
+		body    []byte
+		secret  string
+		want    int
+		wantRun bool
+	}{
+		"unsigned":          {[]byte(`{"event": "changed"}`), "", http.StatusUnauthorized, false},
+		"invalid signature": {[]byte(`{"event": "changed"}`), "other", http.StatusUnauthorized, false},
+		"too large":         {bytes.Repeat([]byte(" "), maxWebhookBody+1), "hook", http.StatusRequestEntityTooLarge, false},
+		"invalid payload":   {[]byte(`not json`), "hook", http.StatusBadRequest, false},
+		"accepted":          {[]byte(`{"event": "changed"}`), "hook", http.StatusAccepted, true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := &fakeSource{}
+			cfg := config.AppConfig{WebhookSecret: "hook", WebhookDebounce: 10 * time.Millisecond}
+			srv, _ := newTestServer(t, cfg, src)
+
+			rec := postWebhook(srv, tt.body, tt.secret)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if ran := src.calls.Load() > 0; ran != tt.wantRun {
+				t.Errorf("sync ran = %v, want %v", ran, tt.wantRun)
+			}
+		})
+	}
+}
+
+func TestWebhookBurstRunsOneSync(t *testing.T) {
+	src := &fakeSource{}
+	cfg := config.AppConfig{WebhookSecret: "hook", WebhookDebounce: 50 * time.Millisecond}
+	srv, _ := newTestServer(t, cfg, src)
+
+	for range 3 {
+		if rec := postWebhook(srv, []byte(`{"event": "changed"}`), "hook"); rec.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+		}
+	}
+	time.Sleep(300 * time.Millisecond)
+	if calls := src.calls.Load(); calls != 1 {
+		t.Errorf("sync ran %d times, want 1", calls)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := map[string]struct {
+		sync        bool
+		readyWindow time.Duration
+		pingErr     error
+		want        int
+		wantStatus  string
+	}{
+		"no sync yet": {
+			readyWindow: time.Minute,
+			want:        http.StatusServiceUnavailable,
+			wantStatus:  "no successful sync yet",
+		},
+		"ready": {
+			sync:        true,
+			readyWindow: time.Minute,
+			want:        http.StatusOK,
+			wantStatus:  "ready",
+		},
+		"last success too old": {
+			sync:        true,
+			readyWindow: time.Nanosecond,
+			want:        http.StatusServiceUnavailable,
+			wantStatus:  "last successful sync 0s ago",
+		},
+		"source unreachable": {
+			sync:        true,
+			readyWindow: time.Minute,
+			pingErr:     errors.New("connection refused"),
+			want:        http.StatusServiceUnavailable,
+			wantStatus:  "fake unreachable: connection refused",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			src := &fakeSource{pingErr: tt.pingErr}
+			srv, s := newTestServer(t, config.AppConfig{}, src)
+			srv.readyWindow = tt.readyWindow
+			if tt.sync {
+				if _, err := s.Run(context.Background()); err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			rec, body := serve(srv, http.MethodGet, "/readyz", "")
+			if rec.Code != tt.want || body["status"] != tt.wantStatus {
+				t.Errorf("GET /readyz = %d %v, want %d %q", rec.Code, body, tt.want, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	src := &fakeSource{identities: []source.Identity{
+		{Username: "alice", Groups: []string{"headscale-dev", "headscale-ops"}},
+		{Username: "bob", Groups: []string{"headscale-dev"}},
+	}}
+	srv, s := newTestServer(t, config.AppConfig{GroupPrefix: "headscale-"}, src)
+
+	rec, body := serve(srv, http.MethodGet, "/status", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("GET /status = %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if _, ok := body["last_success"]; ok || body["outcome"] != "" {
+		t.Errorf("status before the first sync = %v", body)
+	}
+
+	if _, err := s.Run(context.Background()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	_, body = serve(srv, http.MethodGet, "/status", "")
+	for _, key := range []string{"last_run", "duration_seconds", "last_success"} {
+		if _, ok := body[key]; !ok {
+			t.Errorf("status has no %s: %v", key, body)
+		}
+	}
+	if body["outcome"] != metrics.OutcomeSuccess || body["changed"] != true || body["total_groups"] != 2.0 || body["total_users"] != 2.0 {
+		t.Errorf("status after a successful sync = %v", body)
+	}
+	if _, ok := body["error"]; ok {
+		t.Errorf("status of a successful sync has an error: %v", body)
+	}
+	lastSuccess := body["last_success"]
+
+	src.fetchErr = errors.New("ldap down")
+	if _, err := s.Run(context.Background()); err == nil {
+		t.Fatal("Run succeeded, want error")
+	}
+	_, body = serve(srv, http.MethodGet, "/status", "")
+	if body["outcome"] != metrics.OutcomeFailure || body["error"] != "failed to query users with groups from fake: ldap down" {
+		t.Errorf("status after a failed sync = %v", body)
+	}
+	if body["last_success"] != lastSuccess {
+		t.Errorf("last_success = %v, want %v kept from the successful sync", body["last_success"], lastSuccess)
+	}
+}
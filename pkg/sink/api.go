@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"context"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/headscale"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// APISink implements PolicySink using the Headscale policy API.
+type APISink struct {
+	client headscale.HeadscaleClient
+	log    logger.ILogger
+}
+
+var _ PolicySink = (*APISink)(nil)
+
+// NewAPISink creates a sink storing the policy in the Headscale database.
+func NewAPISink(client headscale.HeadscaleClient, log logger.ILogger) *APISink {
+	return &APISink{
+		client: client,
+		log:    log,
+	}
+}
+
+// Name returns the sink identifier.
+func (s *APISink) Name() string {
+	return "api"
+}
+
+// Read fetches the current policy from Headscale.
+func (s *APISink) Read(ctx context.Context) ([]byte, error) {
+	s.log.Debug("Fetching existing policy from Headscale API")
+	policy, err := s.client.GetPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(policy), nil
+}
+
+// Write stores the policy through the Headscale API.
+func (s *APISink) Write(ctx context.Context, policy []byte) error {
+	s.log.Debug("Storing policy through Headscale API")
+	return s.client.SetPolicy(ctx, string(policy))
+}
+
+// Notify is a no-op, Headscale applies a policy set through the API immediately.
+func (s *APISink) Notify(context.Context) error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+// rename replaces a file, swapped in tests to simulate a bind-mounted ACL file
+var rename = os.Rename
+
+// writeFileAtomic writes data to a temporary file in the same directory, syncs it and
+// renames it over path, so readers never see a partially written file. The mode and
+// ownership of an existing file are kept.
+func writeFileAtomic(path string, data []byte, defaultMode fs.FileMode, log logger.ILogger) (err error) {
+	mode := defaultMode
+	info, statErr := os.Stat(path)
+	switch {
+	case statErr == nil:
+		mode = info.Mode().Perm()
+	case !errors.Is(statErr, fs.ErrNotExist):
+		return statErr
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if info != nil {
+		if err = chownLike(tmp, info); err != nil {
+			return err
+		}
+	}
+	if err = tmp.Sync(); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	if err = rename(tmp.Name(), path); err != nil {
+		// A file bind-mounted into a container cannot be replaced, only rewritten in place
+		if errors.Is(err, syscall.EBUSY) {
+			log.Warn("File cannot be replaced atomically, rewriting it in place; mount its directory instead of the file",
+				"path", path)
+			os.Remove(tmp.Name())
+			return writeFileInPlace(path, data)
+		}
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// writeFileInPlace truncates and rewrites path, then syncs it to disk.
+func writeFileInPlace(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir flushes a directory so a rename inside it is durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some filesystems do not support syncing directories, the rename is still atomic there.
+	if err := d.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package sink
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+)
+
+func newTestLogger(t *testing.T) logger.ILogger {
+	t.Helper()
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+	return log
+}
+
+func writeFile(t *testing.T, path, data string, mode os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return string(data)
+}
+
+func TestWriteFileAtomicKeepsModeAndOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.hujson")
+	writeFile(t, path, "old", 0640)
+	owned := os.Geteuid() == 0
+	if owned {
+		if err := os.Chown(path, 1234, 5678); err != nil {
+			t.Fatalf("Chown: %v", err)
+		}
+	}
+
+	if err := writeFileAtomic(path, []byte("new"), 0644, newTestLogger(t)); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("content = %q, want new", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && owned && (stat.Uid != 1234 || stat.Gid != 5678) {
+		t.Errorf("owner = %d:%d, want 1234:5678", stat.Uid, stat.Gid)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
+
+func TestWriteFileAtomicNewFileUsesDefaultMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "acl.hujson")
+	if err := writeFileAtomic(path, []byte("new"), 0600, newTestLogger(t)); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestWriteFileAtomicRewritesBusyFileInPlace(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "acl.hujson")
+	writeFile(t, path, "a much longer old policy", 0644)
+	before, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+
+	rename = func(string, string) error { return &os.LinkError{Op: "rename", Err: syscall.EBUSY} }
+	t.Cleanup(func() { rename = os.Rename })
+
+	if err := writeFileAtomic(path, []byte("new"), 0644, newTestLogger(t)); err != nil {
+		t.Fatalf("writeFileAtomic: %v", err)
+	}
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("content = %q, want new", got)
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !os.SameFile(before, after) {
+		t.Error("file was replaced, want it rewritten in place")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+
+	rename = func(string, string) error { return &os.LinkError{Op: "rename", Err: syscall.EXDEV} }
+	if err := writeFileAtomic(path, []byte("other"), 0644, newTestLogger(t)); !errors.Is(err, syscall.EXDEV) {
+		t.Errorf("writeFileAtomic error = %v, want EXDEV", err)
+	}
+	if got := readFile(t, path); got != "new" {
+		t.Errorf("content after failed rename = %q, want new", got)
+	}
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat sorts lexically in chronological order
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// backupSuffix marks backup files of the ACL file
+const backupSuffix = ".bak"
+
+// LatestBackup selects the most recent backup in Rollback
+const LatestBackup = "latest"
+
+// backup stores the current ACL file content in a new timestamped backup and prunes the oldest ones.
+func (s *FileSink) backup(data []byte) error {
+	if s.backupCount <= 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(s.backupDir, 0755); err != nil {
+		return err
+	}
+
+	name := s.backupPrefix() + time.Now().UTC().Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(s.backupDir, name)
+	if err := writeFileAtomic(path, data, 0600, s.log); err != nil {
+		return err
+	}
+	s.log.Debug("ACL file backed up", "backup", path)
+
+	return s.pruneBackups()
+}
+
+// pruneBackups removes the oldest backups beyond the configured count.
+func (s *FileSink) pruneBackups() error {
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+
+	for len(backups) > s.backupCount {
+		path := filepath.Join(s.backupDir, backups[0])
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		s.log.Debug("Old ACL backup removed", "backup", path)
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// Backups returns the backup file names of the ACL file from oldest to newest.
+func (s *FileSink) Backups() ([]string, error) {
+	entries, err := os.ReadDir(s.backupDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := s.backupPrefix()
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, backupSuffix) {
+			backups = append(backups, name)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// Rollback restores the ACL file from the named backup, or from the newest one for LatestBackup.
+// The current file is backed up first, so a rollback can be undone.
+func (s *FileSink) Rollback(ctx context.Context, name string) error {
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backups found in %s", s.backupDir)
+	}
+
+	if name == LatestBackup {
+		name = backups[len(backups)-1]
+	}
+	name = filepath.Base(name)
+	if !strings.HasPrefix(name, s.backupPrefix()) || !strings.HasSuffix(name, backupSuffix) {
+		return fmt.Errorf("not a backup of %s: %s", s.path, name)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.backupDir, name))
+	if err != nil {
+		return err
+	}
+
+	if err := s.Write(ctx, data); err != nil {
+		return err
+	}
+	s.log.Info("ACL file restored from backup", "path", s.path, "backup", name)
+	return nil
+}
+
+// backupPrefix returns the file name prefix shared by all backups of the ACL file.
+func (s *FileSink) backupPrefix() string {
+	return filepath.Base(s.path) + "."
+}
@@ -0,0 +1,141 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/reload"
+)
+
+func newTestFileSink(t *testing.T, count int, rollback bool, r reload.Reloader, v reload.Verifier) *FileSink {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := config.AppConfig{
+		AclJson:        filepath.Join(dir, "acl.hujson"),
+		AclBackupDir:   filepath.Join(dir, "backups"),
+		AclBackupCount: count,
+		IsAclRollback:  rollback,
+	}
+	return NewFileSink(cfg, r, v, newTestLogger(t))
+}
+
+func TestWriteBacksUpAndPrunes(t *testing.T) {
+	s := newTestFileSink(t, 2, false, nil, nil)
+	ctx := context.Background()
+
+	// Unrelated files in the backup directory are neither listed nor pruned
+	if err := os.MkdirAll(s.backupDir, 0755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	writeFile(t, filepath.Join(s.backupDir, "other.hujson.20000101T000000.000000000Z.bak"), "other", 0600)
+	writeFile(t, filepath.Join(s.backupDir, "acl.hujson.notes"), "notes", 0600)
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := s.Write(ctx, []byte(content)); err != nil {
+			t.Fatalf("Write(%s): %v", content, err)
+		}
+		// Backup names have nanosecond timestamps, keep them distinct on coarse clocks
+		time.Sleep(time.Millisecond)
+	}
+
+	backups, err := s.Backups()
+	if err != nil {
+		t.Fatalf("Backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Backups() = %v, want 2", backups)
+	}
+	if !slices.IsSorted(backups) {
+		t.Errorf("Backups() = %v, want oldest first", backups)
+	}
+	var contents []string
+	for _, name := range backups {
+		contents = append(contents, readFile(t, filepath.Join(s.backupDir, name)))
+	}
+	if !slices.Equal(contents, []string{"v2", "v3"}) {
+		t.Errorf("backup contents = %v, want [v2 v3]", contents)
+	}
+	for _, name := range []string{"other.hujson.20000101T000000.000000000Z.bak", "acl.hujson.notes"} {
+		if _, err := os.Stat(filepath.Join(s.backupDir, name)); err != nil {
+			t.Errorf("unrelated file %s removed: %v", name, err)
+		}
+	}
+}
+
+func TestWriteWithoutBackups(t *testing.T) {
+	s := newTestFileSink(t, 0, false, nil, nil)
+	for _, content := range []string{"v1", "v2"} {
+		if err := s.Write(context.Background(), []byte(content)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if backups, err := s.Backups(); err != nil || len(backups) != 0 {
+		t.Errorf("Backups() = %v, %v, want none", backups, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	s := newTestFileSink(t, 5, false, nil, nil)
+	ctx := context.Background()
+
+	if err := s.Rollback(ctx, LatestBackup); err == nil {
+		t.Error("Rollback without backups succeeded")
+	}
+
+	for _, content := range []string{"v1", "v2", "v3"} {
+		if err := s.Write(ctx, []byte(content)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	backups, err := s.Backups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("Backups() = %v, %v, want 2", backups, err)
+	}
+
+	if err := s.Rollback(ctx, LatestBackup); err != nil {
+		t.Fatalf("Rollback(latest): %v", err)
+	}
+	if got := readFile(t, s.path); got != "v2" {
+		t.Errorf("after Rollback(latest) content = %q, want v2", got)
+	}
+
+	// The replaced file is backed up, so the rollback itself can be undone
+	time.Sleep(time.Millisecond)
+	if err := s.Rollback(ctx, backups[0]); err != nil {
+		t.Fatalf("Rollback(%s): %v", backups[0], err)
+	}
+	if got := readFile(t, s.path); got != "v1" {
+		t.Errorf("after Rollback(%s) content = %q, want v1", backups[0], got)
+	}
+	if after, _ := s.Backups(); len(after) != 4 || readFile(t, filepath.Join(s.backupDir, after[2])) != "v3" {
+		t.Errorf("Backups() after rollback = %v, want the replaced v3 backed up", after)
+	}
+}
+
+func TestRollbackRejectsOtherFiles(t *testing.T) {
+	s := newTestFileSink(t, 5, false, nil, nil)
+	ctx := context.Background()
+	for _, content := range []string{"v1", "v2"} {
+		if err := s.Write(ctx, []byte(content)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(filepath.Dir(s.path), "secret"), "secret", 0600)
+
+	for _, name := range []string{"secret", "../secret", "acl.hujson", "other.hujson.20000101T000000.000000000Z.bak"} {
+		err := s.Rollback(ctx, name)
+		if err == nil || !strings.Contains(err.Error(), "not a backup") {
+			t.Errorf("Rollback(%q) error = %v, want not a backup", name, err)
+		}
+	}
+	if got := readFile(t, s.path); got != "v2" {
+		t.Errorf("content = %q, want v2 unchanged", got)
+	}
+}
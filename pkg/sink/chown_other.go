@@ -0,0 +1,13 @@
+//go:build !unix
+
+package sink
+
+import (
+	"io/fs"
+	"os"
+)
+
+// chownLike is a no-op on platforms without POSIX file ownership.
+func chownLike(_ *os.File, _ fs.FileInfo) error {
+	return nil
+}
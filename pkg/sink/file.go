@@ -0,0 +1,142 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/reload"
+)
+
+// restoreTimeout limits the reload after restoring the previous ACL file
+const restoreTimeout = 30 * time.Second
+
+// FileSink implements PolicySink using the ACL file read by Headscale.
+type FileSink struct {
+	path        string
+	backupDir   string
+	backupCount int
+	rollback    bool
+	reloader    reload.Reloader
+	verifier    reload.Verifier
+	log         logger.ILogger
+
+	// previous holds the content replaced by the last Write, nil if the file did not exist
+	previous []byte
+}
+
+var _ PolicySink = (*FileSink)(nil)
+
+// NewFileSink creates a sink for the ACL file reloaded by the given reloader.
+// A nil verifier disables the post-reload check.
+func NewFileSink(cfg config.AppConfig, reloader reload.Reloader, verifier reload.Verifier, log logger.ILogger) *FileSink {
+	backupDir := cfg.AclBackupDir
+	if backupDir == "" {
+		backupDir = filepath.Dir(cfg.AclJson)
+	}
+
+	return &FileSink{
+		path:        cfg.AclJson,
+		backupDir:   backupDir,
+		backupCount: cfg.AclBackupCount,
+		rollback:    cfg.IsAclRollback,
+		reloader:    reloader,
+		verifier:    verifier,
+		log:         log,
+	}
+}
+
+// Name returns the sink identifier including the file path.
+func (s *FileSink) Name() string {
+	return "file:" + s.path
+}
+
+// Read returns the content of the ACL file.
+func (s *FileSink) Read(context.Context) ([]byte, error) {
+	s.log.Debug("Reading existing ACL file", "path", s.path)
+	return os.ReadFile(s.path)
+}
+
+// Write backs up the ACL file and atomically replaces its content.
+// Once started, the write is not interrupted by ctx so the file is never left half written.
+func (s *FileSink) Write(ctx context.Context, policy []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	previous, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	s.previous = previous
+
+	if previous != nil {
+		if err := s.backup(previous); err != nil {
+			return fmt.Errorf("failed to back up ACL file: %w", err)
+		}
+	}
+
+	s.log.Debug("Writing ACL file", "path", s.path)
+	return writeFileAtomic(s.path, policy, 0644, s.log)
+}
+
+// Notify reloads Headscale with the configured strategy and verifies that it accepted the policy.
+// If the check fails, the previous ACL file is restored and Headscale is reloaded again.
+func (s *FileSink) Notify(ctx context.Context) error {
+	if s.reloader.Name() == reload.StrategyNone {
+		s.log.Info("Headscale reload disabled in config")
+		return nil
+	}
+
+	strategy, _, _ := strings.Cut(s.reloader.Name(), ":")
+	metrics.ReloadAttempts.WithLabelValues(strategy).Inc()
+	err := s.notify(ctx)
+	if err != nil {
+		metrics.ReloadFailures.WithLabelValues(strategy).Inc()
+	}
+	return err
+}
+
+// notify reloads and verifies Headscale, restoring the previous ACL file if it was rejected.
+func (s *FileSink) notify(ctx context.Context) error {
+	s.log.Debug("Reloading headscale", "strategy", s.reloader.Name())
+	if err := s.reloader.Reload(ctx); err != nil {
+		return err
+	}
+
+	if s.verifier == nil {
+		return nil
+	}
+
+	verifyErr := s.verifier.Verify(ctx)
+	if verifyErr == nil {
+		s.log.Debug("Headscale reload verified", "strategy", s.reloader.Name())
+		return nil
+	}
+
+	if !s.rollback || s.previous == nil {
+		return fmt.Errorf("headscale did not accept the new policy: %w", verifyErr)
+	}
+
+	s.log.Warn("Headscale did not accept the new policy, restoring previous ACL file",
+		"path", s.path, "error", verifyErr)
+	if err := writeFileAtomic(s.path, s.previous, 0644, s.log); err != nil {
+		return fmt.Errorf("headscale did not accept the new policy (%w) and restoring the previous ACL file failed: %w", verifyErr, err)
+	}
+	// Reload the restored file even if the run was cancelled, Headscale would otherwise keep the rejected policy
+	restoreCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), restoreTimeout)
+	defer cancel()
+	if err := s.reloader.Reload(restoreCtx); err != nil {
+		s.log.Warn("Failed to reload headscale with the restored ACL file", "error", err)
+	}
+
+	return fmt.Errorf("headscale did not accept the new policy, previous ACL file restored: %w", verifyErr)
+}
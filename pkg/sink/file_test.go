@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeReloader counts reloads and records the ACL file content seen by each one.
+type fakeReloader struct {
+	path string
+	seen []string
+	err  error
+}
+
+func (r *fakeReloader) Name() string { return "fake" }
+
+func (r *fakeReloader) Reload(context.Context) error {
+	data, _ := os.ReadFile(r.path)
+	r.seen = append(r.seen, string(data))
+	return r.err
+}
+
+type fakeVerifier struct {
+	err error
+}
+
+func (v fakeVerifier) Verify(context.Context) error { return v.err }
+
+func TestNotify(t *testing.T) {
+	rejected := errors.New("headscale stopped")
+	tests := map[string]struct {
+		rollback   bool
+		existing   bool
+		verifyErr  error
+		wantErr    string
+		wantFile   string
+		wantReload []string
+	}{
+		"accepted": {
+			rollback:   true,
+			existing:   true,
+			wantFile:   "new",
+			wantReload: []string{"new"},
+		},
+		"rejected with rollback": {
+			rollback:   true,
+			existing:   true,
+			verifyErr:  rejected,
+			wantErr:    "previous ACL file restored",
+			wantFile:   "old",
+			wantReload: []string{"new", "old"},
+		},
+		"rejected without rollback": {
+			existing:   true,
+			verifyErr:  rejected,
+			wantErr:    "did not accept the new policy",
+			wantFile:   "new",
+			wantReload: []string{"new"},
+		},
+		"rejected without previous file": {
+			rollback:   true,
+			verifyErr:  rejected,
+			wantErr:    "did not accept the new policy",
+			wantFile:   "new",
+			wantReload: []string{"new"},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			reloader := &fakeReloader{}
+			s := newTestFileSink(t, 1, tt.rollback, reloader, fakeVerifier{err: tt.verifyErr})
+			reloader.path = s.path
+			if tt.existing {
+				writeFile(t, s.path, "old", 0644)
+			}
+
+			ctx := context.Background()
+			if err := s.Write(ctx, []byte("new")); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			err := s.Notify(ctx)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("Notify: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr) || !errors.Is(err, rejected)):
+				t.Errorf("Notify error = %v, want %q wrapping the verify error", err, tt.wantErr)
+			}
+			if got := readFile(t, s.path); got != tt.wantFile {
+				t.Errorf("content = %q, want %q", got, tt.wantFile)
+			}
+			if !slices.Equal(reloader.seen, tt.wantReload) {
+				t.Errorf("reloads saw %q, want %q", reloader.seen, tt.wantReload)
+			}
+		})
+	}
+}
+
+func TestNotifyReloadFailureSkipsVerify(t *testing.T) {
+	reloader := &fakeReloader{err: errors.New("signal failed")}
+	s := newTestFileSink(t, 1, true, reloader, fakeVerifier{err: errors.New("must not run")})
+	reloader.path = s.path
+	writeFile(t, s.path, "old", 0644)
+	if err := s.Write(context.Background(), []byte("new")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	if err := s.Notify(context.Background()); err == nil || err.Error() != "signal failed" {
+		t.Errorf("Notify error = %v, want the reload error", err)
+	}
+	if got := readFile(t, s.path); got != "new" {
+		t.Errorf("content = %q, want new", got)
+	}
+}
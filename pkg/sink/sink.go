@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/headscale"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/reload"
+)
+
+// Policy modes selecting the sink implementation
+const (
+	ModeFile = "file"
+	ModeAPI  = "api"
+)
+
+// PolicySink reads and stores the Headscale policy in a backend.
+type PolicySink interface {
+	// Name returns a short identifier of the backend used in logs.
+	Name() string
+	// Read returns the current policy document.
+	Read(ctx context.Context) ([]byte, error)
+	// Write stores the new policy document.
+	Write(ctx context.Context, policy []byte) error
+	// Notify tells Headscale that the policy has changed.
+	Notify(ctx context.Context) error
+}
+
+// NewSink creates the sink selected by the configured policy mode.
+func NewSink(cfg *config.Config, log logger.ILogger) (PolicySink, error) {
+	switch cfg.App.PolicyMode {
+	case ModeAPI:
+		client, err := headscale.NewClient(cfg.Headscale, log)
+		if err != nil {
+			return nil, err
+		}
+		return NewAPISink(client, log), nil
+	case ModeFile, "":
+		reloader, err := reload.NewReloader(cfg.Reload, cfg.App.AclJson, log)
+		if err != nil {
+			return nil, err
+		}
+		verifier := reload.NewVerifier(cfg.Reload, reloader, log)
+		return NewFileSink(cfg.App, reloader, verifier, log), nil
+	default:
+		return nil, fmt.Errorf("unknown policy mode: %s", cfg.App.PolicyMode)
+	}
+}
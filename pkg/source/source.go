@@ -0,0 +1,23 @@
+package source
+
+import "context"
+
+// Identity is a normalized user with the names of the groups it belongs to.
+type Identity struct {
+	Username string
+	Email    string
+	Groups   []string
+}
+
+// IdentitySource provides users and their group memberships from a directory.
+type IdentitySource interface {
+	// Name returns a short identifier of the source used in logs.
+	Name() string
+	// FetchIdentities returns all users with their group memberships.
+	FetchIdentities(ctx context.Context) ([]Identity, error)
+}
+
+// Pinger is implemented by sources that can check whether the directory is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,41 @@
+package syncer
+
+import (
+	"strings"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+)
+
+// generateGroups creates the groups map of managed groups from the identities of a source
+func generateGroups(users []source.Identity, ownership policy.Ownership) map[string][]string {
+	groupMap := make(map[string][]string)
+
+	for _, user := range users {
+		for _, group := range user.Groups {
+			if ownership.Manages(group) {
+				identifier := user.Email
+				if !strings.Contains(user.Email, "@") {
+					identifier = user.Username + "@"
+				}
+				key := policy.GroupKeyPrefix + group
+				groupMap[key] = append(groupMap[key], identifier)
+			}
+		}
+	}
+
+	return groupMap
+}
+
+// countUniqueUsersInGroups returns the total number of unique users across all groups
+func countUniqueUsersInGroups(groups map[string][]string) int {
+	userSet := make(map[string]bool)
+
+	for _, userEmails := range groups {
+		for _, email := range userEmails {
+			userSet[email] = true
+		}
+	}
+
+	return len(userSet)
+}
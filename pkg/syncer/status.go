@@ -0,0 +1,55 @@
+package syncer
+
+import (
+	"sync"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+)
+
+// Status describes the last sync run.
+type Status struct {
+	LastRun         time.Time `json:"last_run"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Outcome         string    `json:"outcome"`
+	Error           string    `json:"error,omitempty"`
+	Changed         bool      `json:"changed"`
+	TotalGroups     int       `json:"total_groups"`
+	TotalUsers      int       `json:"total_users"`
+	LastSuccess     time.Time `json:"last_success,omitzero"`
+}
+
+// statusTracker keeps the status of the last run safe for concurrent readers.
+type statusTracker struct {
+	mu     sync.RWMutex
+	status Status
+}
+
+// record stores the outcome of a run that started at start.
+func (t *statusTracker) record(start time.Time, result *Result, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status.LastRun = start
+	t.status.DurationSeconds = time.Since(start).Seconds()
+	t.status.Error = ""
+	if result != nil {
+		t.status.Changed = result.Changed
+		t.status.TotalGroups = result.TotalGroups
+		t.status.TotalUsers = result.TotalUsers
+	}
+
+	t.status.Outcome = metrics.Outcome(err)
+	if err != nil {
+		t.status.Error = err.Error()
+		return
+	}
+	t.status.LastSuccess = start
+}
+
+// get returns a copy of the current status.
+func (t *statusTracker) get() Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status
+}
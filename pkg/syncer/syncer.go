@@ -0,0 +1,352 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/metrics"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/sink"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+)
+
+var (
+	// ErrSyncInProgress is returned when a run is requested while another one is still running.
+	ErrSyncInProgress = errors.New("sync already in progress")
+	// ErrShuttingDown is returned when a run is requested after Shutdown.
+	ErrShuttingDown = errors.New("sync is shutting down")
+)
+
+// Syncer generates Headscale groups from an identity source and stores them in a policy sink.
+type Syncer struct {
+	cfg    *config.Config
+	source source.IdentitySource
+	sink   sink.PolicySink
+	log    logger.ILogger
+	status statusTracker
+
+	// mu makes runs single-flight so two syncs never write the policy at the same time
+	mu sync.Mutex
+
+	// runCtx is cancelled by Shutdown when the running sync does not finish in time
+	runCtx     context.Context
+	cancelRuns context.CancelFunc
+	closing    atomic.Bool
+}
+
+// plan is the computed outcome of a sync before anything is written.
+type plan struct {
+	doc       *policy.Document
+	current   []byte
+	updated   []byte
+	oldGroups map[string][]string
+	newGroups map[string][]string
+}
+
+// Result describes the outcome of a single sync.
+type Result struct {
+	Changed     bool             `json:"changed"`
+	Diff        policy.GroupDiff `json:"diff"`
+	TotalGroups int              `json:"total_groups"`
+	TotalUsers  int              `json:"total_users"`
+}
+
+// NewSyncer creates a sync engine reading from the given source and targeting the given sink.
+func NewSyncer(cfg *config.Config, identitySource source.IdentitySource, policySink sink.PolicySink, log logger.ILogger) *Syncer {
+	runCtx, cancelRuns := context.WithCancel(context.Background())
+	return &Syncer{
+		cfg:        cfg,
+		source:     identitySource,
+		sink:       policySink,
+		log:        log,
+		runCtx:     runCtx,
+		cancelRuns: cancelRuns,
+	}
+}
+
+// Run executes a single sync, records its status and returns the membership changes it applied.
+// It returns ErrSyncInProgress without doing anything while another run is in progress, and
+// gives up once the configured sync timeout has passed or ctx is done.
+func (s *Syncer) Run(ctx context.Context) (*Result, error) {
+	if s.closing.Load() {
+		return nil, ErrShuttingDown
+	}
+	if !s.mu.TryLock() {
+		s.log.Warn("Sync skipped, previous run still in progress", "source", s.source.Name())
+		metrics.SyncRuns.WithLabelValues(s.source.Name(), metrics.OutcomeSkipped).Inc()
+		return nil, ErrSyncInProgress
+	}
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(s.runCtx, cancel)()
+
+	if timeout := s.cfg.App.SyncTimeout; timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	result, err := s.run(ctx)
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		s.log.Warn("Sync cancelled", "source", s.source.Name(), "reason", ctxErr, "elapsed", time.Since(start).Round(time.Millisecond))
+	}
+	s.status.record(start, result, err)
+	s.observe(start, result, err)
+	return result, err
+}
+
+// Shutdown rejects new runs and waits for the running one to finish. When ctx is done first,
+// the running sync is cancelled and Shutdown returns the error of ctx once it has stopped.
+// The syncer cannot be used afterwards.
+func (s *Syncer) Shutdown(ctx context.Context) error {
+	s.closing.Store(true)
+
+	idle := make(chan struct{})
+	go func() {
+		// Keep the lock, no run may start after shutdown
+		s.mu.Lock()
+		close(idle)
+	}()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		s.log.Warn("Shutdown grace period expired, cancelling running sync")
+		s.cancelRuns()
+		<-idle
+		return ctx.Err()
+	}
+}
+
+// observe updates the sync metrics after a run.
+func (s *Syncer) observe(start time.Time, result *Result, err error) {
+	name := s.source.Name()
+	metrics.SyncRuns.WithLabelValues(name, metrics.Outcome(err)).Inc()
+	metrics.SyncDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+
+	if err != nil {
+		return
+	}
+	metrics.LastSuccess.WithLabelValues(name).SetToCurrentTime()
+	if result != nil {
+		metrics.GroupsGenerated.WithLabelValues(name).Set(float64(result.TotalGroups))
+		metrics.MembersGenerated.WithLabelValues(name).Set(float64(result.TotalUsers))
+	}
+}
+
+// Status returns the status of the last sync run.
+func (s *Syncer) Status() Status {
+	return s.status.get()
+}
+
+// run executes a single sync.
+func (s *Syncer) run(ctx context.Context) (*Result, error) {
+	p, err := s.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := p.result()
+	s.logDiff(result.Diff)
+
+	// Abort before touching the policy if it is invalid or too much would be removed
+	if err := policy.Validate(p.doc); err != nil {
+		return result, fmt.Errorf("sync aborted, policy left untouched: %w", err)
+	}
+	if err := s.checkRemovals(p, result); err != nil {
+		return result, err
+	}
+
+	// Check if policy content has changed
+	if !result.Changed {
+		s.log.Info("Policy unchanged, no reload needed", "sink", s.sink.Name())
+		return result, nil
+	}
+
+	s.log.Debug("Policy content changed, updating...", "sink", s.sink.Name())
+	err = s.sink.Write(ctx, p.updated)
+	metrics.PolicyWrites.WithLabelValues(s.sink.Name(), metrics.Outcome(err)).Inc()
+	if err != nil {
+		return nil, fmt.Errorf("failed to write policy to %s: %w", s.sink.Name(), err)
+	}
+
+	s.log.Info("Policy updated successfully",
+		"sink", s.sink.Name(),
+		"changed_groups", len(result.Diff),
+		"total_groups", result.TotalGroups,
+		"total_users_in_groups", result.TotalUsers)
+
+	if err := s.sink.Notify(ctx); err != nil {
+		return result, fmt.Errorf("failed to reload headscale: %w", err)
+	}
+	return result, nil
+}
+
+// DryRun computes the updated policy and writes the changes to w without storing anything or reloading Headscale.
+func (s *Syncer) DryRun(ctx context.Context, w io.Writer) (*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if timeout := s.cfg.App.SyncTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	p, err := s.prepare(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := p.result()
+	fmt.Fprintf(w, "Dry run against %s\n\n", s.sink.Name())
+	result.Diff.Print(w)
+	fmt.Fprintln(w)
+	policy.WriteUnifiedDiff(w, s.sink.Name(), s.sink.Name()+" (updated)", p.current, p.updated)
+
+	if err := policy.Validate(p.doc); err != nil {
+		fmt.Fprintf(w, "\nA real sync would be aborted: %v\n", err)
+	}
+	if err := s.checkRemovals(p, result); err != nil {
+		fmt.Fprintf(w, "\nA real sync would be aborted: %v\n", err)
+	}
+
+	s.log.Info("Dry run complete, nothing written",
+		"sink", s.sink.Name(),
+		"changed", result.Changed)
+	return result, nil
+}
+
+// checkRemovals enforces the configured removal limits, unless forced.
+func (s *Syncer) checkRemovals(p *plan, result *Result) error {
+	limits := policy.RemovalLimits{
+		MaxGroups:         s.cfg.App.MaxRemovedGroups,
+		MaxGroupsPercent:  s.cfg.App.MaxRemovedGroupsPct,
+		MaxMembers:        s.cfg.App.MaxRemovedMembers,
+		MaxMembersPercent: s.cfg.App.MaxRemovedMembersPct,
+	}
+
+	err := limits.Check(p.oldGroups, result.Diff, s.ownership())
+	if err == nil {
+		return nil
+	}
+	if s.cfg.App.IsForce {
+		s.log.Warn("Removal limit exceeded, continuing because force is enabled", "error", err)
+		return nil
+	}
+	return fmt.Errorf("sync aborted, policy left untouched: %w", err)
+}
+
+// logDiff logs every changed group with its added and removed members.
+func (s *Syncer) logDiff(diff policy.GroupDiff) {
+	for _, change := range diff {
+		s.log.Info("Group membership changed",
+			"group", change.Group,
+			"status", change.Status,
+			"added", change.Added,
+			"removed", change.Removed)
+	}
+}
+
+// prepare queries the source, reads the current policy and computes the updated one.
+func (s *Syncer) prepare(ctx context.Context) (*plan, error) {
+	s.log.Info("Querying users with groups...", "source", s.source.Name())
+	users, err := s.source.FetchIdentities(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users with groups from %s: %w", s.source.Name(), err)
+	}
+	s.log.Info("Source query complete", "source", s.source.Name(), "total_users", len(users))
+
+	// Load existing policy
+	current, err := s.sink.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy from %s: %w", s.sink.Name(), err)
+	}
+
+	return buildUpdatedPolicy(current, users, s.ownership(), s.cfg.App.IsMergeMembers, s.log)
+}
+
+// result summarizes the plan.
+func (p *plan) result() *Result {
+	return &Result{
+		Changed:     string(p.updated) != string(p.current),
+		Diff:        policy.DiffGroups(p.oldGroups, p.newGroups),
+		TotalGroups: len(p.newGroups),
+		TotalUsers:  countUniqueUsersInGroups(p.newGroups),
+	}
+}
+
+// ownership returns which groups are managed by the sync according to the config
+func (s *Syncer) ownership() policy.Ownership {
+	return Ownership(s.cfg.App)
+}
+
+// Ownership returns which groups are managed by the sync according to the app config.
+func Ownership(cfg config.AppConfig) policy.Ownership {
+	return policy.Ownership{
+		Prefix: cfg.GroupPrefix,
+		Groups: cfg.ManagedGroups,
+	}
+}
+
+// buildUpdatedPolicy parses the existing policy and replaces its managed groups with the ones generated from the source.
+func buildUpdatedPolicy(data []byte, users []source.Identity, ownership policy.Ownership, mergeMembers bool, log logger.ILogger) (*plan, error) {
+	// Parse the existing policy, keeping HuJSON comments and formatting
+	log.Debug("Parsing existing policy")
+	doc, err := policy.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	existingGroups, err := doc.Groups()
+	if err != nil {
+		return nil, err
+	}
+	referenced, err := doc.GroupReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate new groups from the source and merge them with the unmanaged ones
+	log.Debug("Generating new groups from source data")
+	newGroups := policy.MergeGroups(existingGroups, generateGroups(users, ownership), ownership, mergeMembers, referenced)
+
+	// Replace only the groups section, leaving the rest of the document as it was
+	log.Debug("Replacing groups in policy")
+	if err := doc.SetGroups(newGroups); err != nil {
+		return nil, err
+	}
+
+	// Make sure every other section survives the round-trip untouched
+	updated := doc.Bytes()
+	original, err := policy.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	result, err := policy.Parse(updated)
+	if err != nil {
+		return nil, err
+	}
+	if err := policy.VerifyPreserved(original, result, policy.SectionGroups); err != nil {
+		return nil, err
+	}
+	log.Debug("Preserved policy sections", "sections", original.Sections())
+
+	return &plan{
+		doc:       result,
+		current:   data,
+		updated:   updated,
+		oldGroups: existingGroups,
+		newGroups: newGroups,
+	}, nil
+}
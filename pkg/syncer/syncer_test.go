@@ -0,0 +1,121 @@
+package syncer
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/config"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/logger"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/policy"
+	"hu.jandzsogyorgy.headscale-oidc-sync/pkg/source"
+)
+
+func TestBuildUpdatedPolicyRevokesLastMember(t *testing.T) {
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := []byte(`{
+  "groups": {
+    "group:headscale-admins": ["alice@"],
+    "group:headscale-dev": ["carol@", "dave@"],
+  },
+  "acls": [
+    {"action": "accept", "src": ["group:headscale-admins"], "dst": ["*:*"]},
+  ],
+}`)
+	users := []source.Identity{
+		{Username: "bob", Groups: []string{"staff"}},
+		{Username: "carol", Groups: []string{"headscale-dev"}},
+		{Username: "dave", Groups: []string{"headscale-dev"}},
+		{Username: "erin", Groups: []string{"headscale-dev"}},
+	}
+	ownership := policy.Ownership{Prefix: "headscale-"}
+
+	p, err := buildUpdatedPolicy(current, users, ownership, false, log)
+	if err != nil {
+		t.Fatalf("buildUpdatedPolicy() error = %v", err)
+	}
+
+	members, ok := p.newGroups["group:headscale-admins"]
+	if !ok || len(members) != 0 {
+		t.Fatalf("group:headscale-admins = %v (exists %v), want an empty group", members, ok)
+	}
+	if err := policy.Validate(p.doc); err != nil {
+		t.Fatalf("Validate() error = %v, want the emptied group to be valid", err)
+	}
+
+	groups, err := p.doc.Groups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if members := groups["group:headscale-admins"]; members == nil || len(members) != 0 {
+		t.Fatalf("written group = %#v, want []", members)
+	}
+
+	if dev := groups["group:headscale-dev"]; len(dev) != 3 {
+		t.Fatalf("group:headscale-dev = %v, want the addition applied", dev)
+	}
+
+	// Revoking the last member is a normal change, not a removal limit violation
+	s := &Syncer{cfg: &config.Config{App: config.AppConfig{MaxRemovedGroupsPct: 50, MaxRemovedMembersPct: 50}}, log: log}
+	if err := s.checkRemovals(p, p.result()); err != nil {
+		t.Fatalf("checkRemovals() error = %v, want nil", err)
+	}
+}
+
+func TestBuildUpdatedPolicyDropsUnreferencedEmptyGroup(t *testing.T) {
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current := []byte(`{"groups": {"group:headscale-old": ["alice@"]}, "acls": []}`)
+	p, err := buildUpdatedPolicy(current, nil, policy.Ownership{Prefix: "headscale-"}, false, log)
+	if err != nil {
+		t.Fatalf("buildUpdatedPolicy() error = %v", err)
+	}
+	if _, ok := p.newGroups["group:headscale-old"]; ok {
+		t.Fatalf("unreferenced managed group without members was kept: %v", p.newGroups)
+	}
+}
+
+func TestBuildUpdatedPolicyKeepsUnmanagedGroupsVerbatim(t *testing.T) {
+	log, err := logger.NewLogger(config.Config{}, io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unmanaged groups keep their comments, formatting and position, only the managed group changes
+	policyText := `{
+  "groups": {
+    // Maintained by hand, see the runbook
+    "group:breakglass": [
+      "root@",   // local admin
+      "ops@example.com",
+    ],
+    "group:headscale-dev": [%s],
+    "group:vendors":["v@example.com"] /* contractors */,
+  },
+  "acls": [
+    {"action": "accept", "src": ["group:headscale-dev", "group:breakglass", "group:vendors"], "dst": ["*:*"]},
+  ],
+}`
+	current := []byte(fmt.Sprintf(policyText, `"alice@"`))
+	want := fmt.Sprintf(policyText, `"alice@", "bob@"`)
+	users := []source.Identity{
+		{Username: "alice", Groups: []string{"headscale-dev"}},
+		{Username: "bob", Groups: []string{"headscale-dev"}},
+	}
+
+	p, err := buildUpdatedPolicy(current, users, policy.Ownership{Prefix: "headscale-"}, false, log)
+	if err != nil {
+		t.Fatalf("buildUpdatedPolicy() error = %v", err)
+	}
+
+	if got := string(p.updated); got != want {
+		t.Errorf("updated policy:\n%s\nwant:\n%s", got, want)
+	}
+}
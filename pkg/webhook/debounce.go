@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+)
+
+// Debouncer calls a function once after a burst of triggers has been quiet for a delay.
+type Debouncer struct {
+	delay time.Duration
+	fn    func()
+
+	mu      sync.Mutex
+	timer   *time.Timer
+	stopped bool
+}
+
+// NewDebouncer creates a debouncer calling fn delay after the last trigger.
+func NewDebouncer(delay time.Duration, fn func()) *Debouncer {
+	return &Debouncer{delay: delay, fn: fn}
+}
+
+// Trigger schedules fn, postponing an already scheduled call.
+func (d *Debouncer) Trigger() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.stopped {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	d.timer = time.AfterFunc(d.delay, d.fn)
+}
+
+// Stop cancels a scheduled call and ignores further triggers.
+func (d *Debouncer) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.stopped = true
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDebouncerCollapsesBurst(t *testing.T) {
+	var calls atomic.Int32
+	d := NewDebouncer(50*time.Millisecond, func() { calls.Add(1) })
+
+	for range 5 {
+		d.Trigger()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := calls.Load(); got != 0 {
+		t.Fatalf("fn called %d times during the burst, want 0", got)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if got := calls.Load(); got != 1 {
+		t.Errorf("fn called %d times after the burst, want 1", got)
+	}
+
+	// A later trigger schedules another call
+	d.Trigger()
+	time.Sleep(200 * time.Millisecond)
+	if got := calls.Load(); got != 2 {
+		t.Errorf("fn called %d times after the second trigger, want 2", got)
+	}
+}
+
+func TestDebouncerStop(t *testing.T) {
+	var calls atomic.Int32
+	d := NewDebouncer(50*time.Millisecond, func() { calls.Add(1) })
+
+	d.Trigger()
+	d.Stop()
+	d.Trigger()
+
+	time.Sleep(200 * time.Millisecond)
+	if got := calls.Load(); got != 0 {
+		t.Errorf("fn called %d times after Stop, want 0", got)
+	}
+}
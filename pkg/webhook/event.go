@@ -0,0 +1,99 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Payload formats
+const (
+	FormatGeneric   = "generic"
+	FormatAuthentik = "authentik"
+	FormatKeycloak  = "keycloak"
+)
+
+// Event is a change notification received from the identity provider.
+type Event struct {
+	Format string
+	Type   string
+	// Relevant is false for events that cannot change group membership, e.g. logins
+	Relevant bool
+}
+
+// payload holds the fields used to recognize the supported formats.
+type payload struct {
+	// Generic
+	Event string `json:"event"`
+
+	// Authentik notification webhook
+	Body           string `json:"body"`
+	Severity       string `json:"severity"`
+	UserEmail      string `json:"user_email"`
+	EventUserEmail string `json:"event_user_email"`
+	EventUserName  string `json:"event_user_username"`
+
+	// Keycloak user and admin events
+	Type          string `json:"type"`
+	RealmID       string `json:"realmId"`
+	OperationType string `json:"operationType"`
+	ResourceType  string `json:"resourceType"`
+}
+
+// keycloakResources are the admin event resource types that can change group membership.
+var keycloakResources = []string{"USER", "GROUP", "GROUP_MEMBERSHIP", "REALM_ROLE_MAPPING", "CLIENT_ROLE_MAPPING"}
+
+// ParseEvent detects the payload format and whether the event can change group membership.
+func ParseEvent(data []byte) (*Event, error) {
+	var p payload
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+
+	switch {
+	case p.RealmID != "":
+		return parseKeycloak(p), nil
+	case p.Body != "" && (p.Severity != "" || p.UserEmail != "" || p.EventUserEmail != "" || p.EventUserName != ""):
+		// Authentik only sends what its notification rule matched, so every event is relevant
+		return &Event{Format: FormatAuthentik, Type: p.Severity, Relevant: true}, nil
+	default:
+		eventType := p.Event
+		if eventType == "" {
+			eventType = p.Type
+		}
+		return &Event{Format: FormatGeneric, Type: eventType, Relevant: true}, nil
+	}
+}
+
+// parseKeycloak handles both admin events and user events of Keycloak.
+func parseKeycloak(p payload) *Event {
+	// Admin events carry the changed resource, user events only a type like LOGIN
+	if p.ResourceType != "" {
+		eventType := p.ResourceType
+		if p.OperationType != "" {
+			eventType += "-" + p.OperationType
+		}
+		relevant := false
+		for _, resource := range keycloakResources {
+			if strings.EqualFold(p.ResourceType, resource) {
+				relevant = true
+				break
+			}
+		}
+		return &Event{Format: FormatKeycloak, Type: eventType, Relevant: relevant}
+	}
+
+	// Webhook extensions prefix admin events with "admin." in the type field
+	eventType := p.Type
+	relevant := false
+	if rest, ok := strings.CutPrefix(eventType, "admin."); ok {
+		resource, _, _ := strings.Cut(rest, "-")
+		for _, r := range keycloakResources {
+			if strings.EqualFold(resource, r) {
+				relevant = true
+				break
+			}
+		}
+	}
+	return &Event{Format: FormatKeycloak, Type: eventType, Relevant: relevant}
+}
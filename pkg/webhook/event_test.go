@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := map[string]struct {
+		payload string
+		want    Event
+	}{
+		"generic event": {
+			payload: `{"event":"group.member_added"}`,
+			want:    Event{Format: FormatGeneric, Type: "group.member_added", Relevant: true},
+		},
+		"generic type": {
+			payload: `{"type":"sync"}`,
+			want:    Event{Format: FormatGeneric, Type: "sync", Relevant: true},
+		},
+		"empty object": {
+			payload: `{}`,
+			want:    Event{Format: FormatGeneric, Relevant: true},
+		},
+		"authentik": {
+			payload: `{"body":"User alice was added to group admins","severity":"notice","user_email":"admin@example.com"}`,
+			want:    Event{Format: FormatAuthentik, Type: "notice", Relevant: true},
+		},
+		"keycloak admin event": {
+			payload: `{"realmId":"master","operationType":"CREATE","resourceType":"GROUP_MEMBERSHIP"}`,
+			want:    Event{Format: FormatKeycloak, Type: "GROUP_MEMBERSHIP-CREATE", Relevant: true},
+		},
+		"keycloak unrelated admin event": {
+			payload: `{"realmId":"master","operationType":"UPDATE","resourceType":"CLIENT"}`,
+			want:    Event{Format: FormatKeycloak, Type: "CLIENT-UPDATE", Relevant: false},
+		},
+		"keycloak login": {
+			payload: `{"realmId":"master","type":"LOGIN"}`,
+			want:    Event{Format: FormatKeycloak, Type: "LOGIN", Relevant: false},
+		},
+		"keycloak extension admin event": {
+			payload: `{"realmId":"master","type":"admin.USER-UPDATE"}`,
+			want:    Event{Format: FormatKeycloak, Type: "admin.USER-UPDATE", Relevant: true},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseEvent([]byte(tt.payload))
+			if err != nil {
+				t.Fatalf("ParseEvent: %v", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("ParseEvent() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+
+	for _, payload := range []string{``, `not json`, `[]`} {
+		if _, err := ParseEvent([]byte(payload)); err == nil {
+			t.Errorf("ParseEvent(%q) succeeded, want error", payload)
+		}
+	}
+}
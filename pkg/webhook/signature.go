@@ -0,0 +1,49 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ErrInvalidSignature is returned when a webhook is unsigned or its signature does not match.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// signatureHeaders are checked in order for the hex encoded HMAC-SHA256 of the body.
+// The value may carry a "sha256=" prefix as sent by GitHub style senders.
+var signatureHeaders = []string{
+	"X-Signature-256",
+	"X-Hub-Signature-256",
+	"X-Keycloak-Signature",
+	"X-Authentik-Signature",
+	"X-Signature",
+}
+
+// VerifySignature checks the HMAC-SHA256 signature of body against the shared secret.
+func VerifySignature(secret []byte, header http.Header, body []byte) error {
+	signature := ""
+	for _, name := range signatureHeaders {
+		if value := header.Get(name); value != "" {
+			signature = strings.TrimPrefix(strings.TrimSpace(value), "sha256=")
+			break
+		}
+	}
+	if signature == "" {
+		return ErrInvalidSignature
+	}
+
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	const secret, body = "s3cret", `{"event":"user.updated"}`
+	valid := sign(secret, body)
+
+	tests := map[string]struct {
+		header http.Header
+		ok     bool
+	}{
+		"plain hex":         {http.Header{"X-Signature-256": {valid}}, true},
+		"sha256 prefix":     {http.Header{"X-Hub-Signature-256": {"sha256=" + valid}}, true},
+		"keycloak header":   {http.Header{"X-Keycloak-Signature": {" " + valid + " "}}, true},
+		"first header wins": {http.Header{"X-Signature-256": {sign("other", body)}, "X-Signature": {valid}}, false},
+		"missing":           {http.Header{}, false},
+		"not hex":           {http.Header{"X-Signature": {"zz"}}, false},
+		"wrong secret":      {http.Header{"X-Signature": {sign("other", body)}}, false},
+		"other body":        {http.Header{"X-Signature": {sign(secret, body+" ")}}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := VerifySignature([]byte(secret), tt.header, []byte(body))
+			if tt.ok && err != nil {
+				t.Errorf("VerifySignature() = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignature() = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}